@@ -0,0 +1,283 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import "fmt"
+
+// Height returns the number of nodes on the longest path from the root to a
+// leaf, the empty tree has height 0.
+func (t *Rbtree) Height() int {
+	return t.height(t.root)
+}
+
+func (t *Rbtree) height(x *Node) int {
+	if x == t.NIL {
+		return 0
+	}
+
+	l, r := t.height(x.left), t.height(x.right)
+	if l > r {
+		return l + 1
+	}
+	return r + 1
+}
+
+// BlackHeight returns the number of black nodes on the path from the root to
+// any leaf, which is the same for all the paths in a valid tree.
+func (t *Rbtree) BlackHeight() int {
+	return t.blackHeight(t.root)
+}
+
+// SetDebug enables or disables the debug mode, which runs CheckInvariants
+// after every change of the items, e.g. by Insert, Delete, PopMin or
+// BulkInsertSorted, and panics with the operation if the tree has become
+// invalid. Only Clear and Init, which leave the tree empty, are not checked.
+// It is meant for the tests and costs O(n) for each item inserted or
+// deleted, so it is disabled by default.
+func (t *Rbtree) SetDebug(debug bool) {
+	t.debug = debug
+}
+
+// verify panics if the debug mode is enabled and the tree is invalid after
+// the operation op of the item, which is nil if op is not about one item.
+func (t *Rbtree) verify(op string, item Item) {
+	if !t.debug {
+		return
+	}
+
+	if err := t.CheckInvariants(); err != nil {
+		if item != nil {
+			op = fmt.Sprintf("%s(%v)", op, item)
+		} else {
+			op += "()"
+		}
+		panic(fmt.Sprintf("rbtree: invalid tree after %s: %v", op, err))
+	}
+}
+
+// SetCompareCounting enables or disables counting the comparisons of the
+// items for profiling, which costs an atomic addition for each of them. It
+// is disabled by default and a disabled tree does not count at all.
+func (t *Rbtree) SetCompareCounting(on bool) {
+	t.counting = on
+}
+
+// CompareCount returns the number of comparisons made since the counting
+// was enabled or since the last ResetCompareCount.
+func (t *Rbtree) CompareCount() int64 {
+	return t.compares.Load()
+}
+
+// ResetCompareCount sets the number of comparisons back to 0.
+func (t *Rbtree) ResetCompareCount() {
+	t.compares.Store(0)
+}
+
+// TreeStats describes the shape of a tree, see Stats.
+type TreeStats struct {
+	// Nodes is the number of nodes, i.e. of items.
+	Nodes int
+	// Height is the same as returned by Height.
+	Height int
+	// BlackHeight is the same as returned by BlackHeight.
+	BlackHeight int
+	// Red and Black are the number of nodes of each color.
+	Red, Black int
+	// MinDepth and MaxDepth are the number of nodes on the shortest and the
+	// longest paths from the root to a leaf, MaxDepth equals Height.
+	MinDepth, MaxDepth int
+}
+
+// Stats walks the whole tree and returns its TreeStats. In a valid tree
+// MaxDepth is at most twice MinDepth.
+func (t *Rbtree) Stats() TreeStats {
+	s := TreeStats{Nodes: t.count, BlackHeight: t.BlackHeight()}
+	s.MinDepth, s.MaxDepth = t.stats(t.root, &s)
+	s.Height = s.MaxDepth
+	return s
+}
+
+// stats counts the colors of the subtree rooted at x into s and returns the
+// minimum and maximum depths of its leaves.
+func (t *Rbtree) stats(x *Node, s *TreeStats) (int, int) {
+	if x == t.NIL {
+		return 0, 0
+	}
+
+	if x.color == RED {
+		s.Red++
+	} else {
+		s.Black++
+	}
+
+	lmin, lmax := t.stats(x.left, s)
+	rmin, rmax := t.stats(x.right, s)
+	if rmin < lmin {
+		lmin = rmin
+	}
+	if rmax > lmax {
+		lmax = rmax
+	}
+	return lmin + 1, lmax + 1
+}
+
+// ViolationKind is the kind of a Violation.
+type ViolationKind int
+
+// The kinds of the violations reported by Verify.
+const (
+	// ViolationColor is a node which is neither red nor black, or a red
+	// root or NIL.
+	ViolationColor ViolationKind = iota
+	// ViolationRedRed is a red node which has a red child.
+	ViolationRedRed
+	// ViolationBlackHeight is a node whose subtrees have different black
+	// heights.
+	ViolationBlackHeight
+	// ViolationLink is a parent pointer which does not match the children.
+	ViolationLink
+	// ViolationSize is a wrong size of a subtree or count of the tree.
+	ViolationSize
+	// ViolationWeight is a wrong sum of the weights of a subtree.
+	ViolationWeight
+	// ViolationOrder is an item which is out of order.
+	ViolationOrder
+)
+
+func (k ViolationKind) String() string {
+	switch k {
+	case ViolationColor:
+		return "color"
+	case ViolationRedRed:
+		return "red-red"
+	case ViolationBlackHeight:
+		return "black-height"
+	case ViolationLink:
+		return "link"
+	case ViolationSize:
+		return "size"
+	case ViolationWeight:
+		return "weight"
+	case ViolationOrder:
+		return "order"
+	}
+	return fmt.Sprintf("ViolationKind(%d)", int(k))
+}
+
+// Violation describes a violation of the invariants of a tree found by
+// Verify, a Violation is also the error returned by CheckInvariants.
+type Violation struct {
+	Kind ViolationKind
+	// Item is the item of the node at fault, it is nil if the violation
+	// is about the whole tree.
+	Item        Item
+	Description string
+}
+
+func (v Violation) Error() string {
+	return "rbtree: " + v.Description
+}
+
+// CheckInvariants verifies the Red-Black tree properties as well as the
+// order of the items and the bookkeeping of the tree, it returns an error
+// describing the first violation found. See Verify for all of them.
+func (t *Rbtree) CheckInvariants() error {
+	if violations := t.Verify(); len(violations) > 0 {
+		return violations[0]
+	}
+	return nil
+}
+
+// Verify checks the same invariants as CheckInvariants and returns all the
+// violations found rather than the first one only, so that the whole
+// damage of a corrupted tree can be seen at once. It returns nil for a
+// valid tree. The order of the items is only checked if the nodes are
+// linked correctly.
+func (t *Rbtree) Verify() []Violation {
+	var violations []Violation
+	report := func(kind ViolationKind, item Item, format string, args ...interface{}) {
+		violations = append(violations, Violation{kind, item, fmt.Sprintf(format, args...)})
+	}
+
+	if t.NIL.color != BLACK {
+		report(ViolationColor, nil, "NIL is not black")
+	}
+	if t.root.color != BLACK {
+		report(ViolationColor, t.root.Item, "root %v is not black", t.root.Item)
+	}
+
+	if t.root != t.NIL && t.root.parent != t.NIL {
+		report(ViolationLink, t.root.Item, "root %v has a parent", t.root.Item)
+	}
+	t.checkNode(t.root, report)
+
+	if t.root.size != t.count {
+		report(ViolationSize, nil, "count is %d but there are %d nodes", t.count, t.root.size)
+	}
+	if t.lru != nil && len(t.lru.nodes) != t.count {
+		report(ViolationSize, nil, "count is %d but %d nodes are ordered by their accesses", t.count, len(t.lru.nodes))
+	}
+
+	linked := true
+	for _, v := range violations {
+		linked = linked && v.Kind != ViolationLink
+	}
+	if !linked {
+		return violations
+	}
+
+	var prev Item
+	t.walk(t.root, func(i Item) bool {
+		if prev != nil && t.dup && t.less(i, prev) {
+			report(ViolationOrder, prev, "%v is greater than its successor %v", prev, i)
+		}
+		if prev != nil && !t.dup && !t.less(prev, i) {
+			report(ViolationOrder, prev, "%v is not less than its successor %v", prev, i)
+		}
+		prev = i
+		return true
+	})
+
+	return violations
+}
+
+// checkNode reports the violations in the subtree rooted at x and returns
+// its black height, which is the one of the left subtree if they differ.
+func (t *Rbtree) checkNode(x *Node, report func(kind ViolationKind, item Item, format string, args ...interface{})) int {
+	if x == t.NIL {
+		return 0
+	}
+
+	if x.color != RED && x.color != BLACK {
+		report(ViolationColor, x.Item, "node %v has unknown color %d", x.Item, x.color)
+	}
+	if x.color == RED && (x.left.color == RED || x.right.color == RED) {
+		report(ViolationRedRed, x.Item, "red node %v has a red child", x.Item)
+	}
+	if x.left != t.NIL && x.left.parent != x {
+		report(ViolationLink, x.left.Item, "left child %v of %v does not refer to it as parent", x.left.Item, x.Item)
+	}
+	if x.right != t.NIL && x.right.parent != x {
+		report(ViolationLink, x.right.Item, "right child %v of %v does not refer to it as parent", x.right.Item, x.Item)
+	}
+
+	lbh := t.checkNode(x.left, report)
+	rbh := t.checkNode(x.right, report)
+	if lbh != rbh {
+		report(ViolationBlackHeight, x.Item, "node %v has black height %d on the left but %d on the right", x.Item, lbh, rbh)
+	}
+
+	if size := x.left.size + x.right.size + 1; x.size != size {
+		report(ViolationSize, x.Item, "node %v has size %d, expect %d", x.Item, x.size, size)
+	}
+	if sum := x.left.sum + x.right.sum + t.weight(x.Item); x.sum != sum {
+		report(ViolationWeight, x.Item, "node %v has weight %v, expect %v", x.Item, x.sum, sum)
+	}
+
+	if x.color == BLACK {
+		lbh++
+	}
+	return lbh
+}
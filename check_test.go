@@ -0,0 +1,342 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckInvariants(t *testing.T) {
+	for round := 0; round < 5; round++ {
+		rbt := New()
+		n := 10000
+		for rbt.Len() < n {
+			rbt.Insert(Int(rand.Intn(10 * n)))
+		}
+		if err := rbt.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+
+		if h, max := rbt.Height(), 2*math.Log2(float64(n+1)); float64(h) > max {
+			t.Errorf("tree.Height() = %d, expect no more than %v", h, max)
+		}
+		if bh := rbt.BlackHeight(); bh > rbt.Height() || 2*bh < rbt.Height() {
+			t.Errorf("tree.BlackHeight() = %d does not agree with tree.Height() = %d", bh, rbt.Height())
+		}
+
+		for i := 0; i < n/2; i++ {
+			rbt.Delete(Int(rand.Intn(10 * n)))
+		}
+		if err := rbt.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	if s := New().Stats(); s != (TreeStats{}) {
+		t.Errorf("Stats() = %+v on an empty tree", s)
+	}
+
+	rbt := New()
+	for _, v := range rand.Perm(1000) {
+		rbt.Insert(Int(v))
+	}
+	for v := 0; v < 1000; v += 3 {
+		rbt.Delete(Int(v))
+	}
+
+	s := rbt.Stats()
+	if s.Nodes != rbt.Len() || s.Red+s.Black != s.Nodes {
+		t.Errorf("Stats() = %+v, expect %d nodes", s, rbt.Len())
+	}
+	if s.Height != rbt.Height() || s.MaxDepth != s.Height {
+		t.Errorf("Stats() = %+v, expect height %d", s, rbt.Height())
+	}
+	if s.BlackHeight != rbt.BlackHeight() || s.BlackHeight > s.MinDepth {
+		t.Errorf("Stats() = %+v, expect black height %d", s, rbt.BlackHeight())
+	}
+	if s.MinDepth > s.MaxDepth || s.MaxDepth > 2*s.MinDepth {
+		t.Errorf("Stats() = %+v, depths are out of balance", s)
+	}
+}
+
+func TestCompareCount(t *testing.T) {
+	rbt := New()
+	for i := 0; i < 100; i++ {
+		rbt.Insert(Int(i))
+	}
+	if n := rbt.CompareCount(); n != 0 {
+		t.Errorf("CompareCount() = %d while disabled, expect 0", n)
+	}
+
+	rbt = New()
+	rbt.SetCompareCounting(true)
+	n := 1 << 12
+	for _, v := range rand.Perm(n) {
+		rbt.Insert(Int(v))
+	}
+
+	// Each insertion makes at most two comparisons per level.
+	logN := math.Log2(float64(n))
+	if c := rbt.CompareCount(); float64(c) < float64(n) || float64(c) > 4*float64(n)*logN {
+		t.Errorf("CompareCount() = %d after %d insertions, expect about n log n", c, n)
+	}
+
+	rbt.ResetCompareCount()
+	rbt.Contains(Int(n))
+	if c := rbt.CompareCount(); c == 0 || float64(c) > 4*logN {
+		t.Errorf("CompareCount() = %d after a search, expect about log n", c)
+	}
+
+	rbt.SetCompareCounting(false)
+	rbt.ResetCompareCount()
+	rbt.Contains(Int(1))
+	if c := rbt.CompareCount(); c != 0 {
+		t.Errorf("CompareCount() = %d while disabled, expect 0", c)
+	}
+}
+
+func TestAscendRangeCompareCount(t *testing.T) {
+	var sorted []Item
+	for i := 1; i <= 15; i++ {
+		sorted = append(sorted, Int(i))
+	}
+	rbt := New()
+	rbt.BulkInsertSorted(sorted)
+	rbt.SetCompareCounting(true)
+
+	// Each node of a range covering the whole tree is compared once with
+	// either bound.
+	rbt.AscendRange(Int(0), Int(100), func(Item) bool { return true })
+	if c := rbt.CompareCount(); c != 30 {
+		t.Errorf("AscendRange() made %d comparisons, expect %d", c, 30)
+	}
+	rbt.ResetCompareCount()
+	rbt.AscendInclusive(Int(0), Int(100), func(Item) bool { return true })
+	if c := rbt.CompareCount(); c != 30 {
+		t.Errorf("AscendInclusive() made %d comparisons, expect %d", c, 30)
+	}
+}
+
+func TestCheckInvariantsViolation(t *testing.T) {
+	rbt := New()
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Errorf("empty tree: %v", err)
+	}
+	if rbt.Height() != 0 || rbt.BlackHeight() != 0 {
+		t.Errorf("empty tree has height %d and black height %d", rbt.Height(), rbt.BlackHeight())
+	}
+
+	for i := 0; i < 10; i++ {
+		rbt.Insert(Int(i))
+	}
+
+	rbt.root.color = RED
+	if err := rbt.CheckInvariants(); err == nil {
+		t.Errorf("expect an error for a red root")
+	}
+	rbt.root.color = BLACK
+
+	rbt.root.left.Item, rbt.root.right.Item = rbt.root.right.Item, rbt.root.left.Item
+	if err := rbt.CheckInvariants(); err == nil {
+		t.Errorf("expect an error for a disordered tree")
+	}
+	rbt.root.left.Item, rbt.root.right.Item = rbt.root.right.Item, rbt.root.left.Item
+
+	rbt.root.left.color = 1 - rbt.root.left.color
+	if err := rbt.CheckInvariants(); err == nil {
+		t.Errorf("expect an error for unbalanced black heights")
+	}
+	rbt.root.left.color = 1 - rbt.root.left.color
+
+	left := rbt.root.left
+	left.left.parent = rbt.root
+	if err := rbt.CheckInvariants(); err == nil {
+		t.Errorf("expect an error for a wrong parent")
+	}
+	left.left.parent = left
+
+	rbt.root.parent = rbt.root.left
+	if err := rbt.CheckInvariants(); err == nil {
+		t.Errorf("expect an error for a root with parent")
+	}
+	rbt.root.parent = rbt.NIL
+
+	rbt.count++
+	if err := rbt.CheckInvariants(); err == nil {
+		t.Errorf("expect an error for a wrong count")
+	}
+	rbt.count--
+
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	rbt := New()
+	if v := rbt.Verify(); v != nil {
+		t.Errorf("empty tree has violations %v", v)
+	}
+
+	var sorted []Item
+	for i := 1; i <= 15; i++ {
+		sorted = append(sorted, Int(i))
+	}
+	rbt.BulkInsertSorted(sorted)
+	if v := rbt.Verify(); v != nil {
+		t.Errorf("valid tree has violations %v", v)
+	}
+
+	// Paint the root and its left child red and swap two leaves.
+	c := rbt.Clone()
+	root, left := c.root, c.root.left
+	root.color, left.color = RED, RED
+	first, last := c.min(c.root), c.max(c.root)
+	first.Item, last.Item = last.Item, first.Item
+
+	violations := c.Verify()
+	kinds := map[ViolationKind]Item{}
+	for _, v := range violations {
+		if _, ok := kinds[v.Kind]; !ok {
+			kinds[v.Kind] = v.Item
+		}
+		if !strings.HasPrefix(v.Error(), "rbtree: ") || v.Description == "" {
+			t.Errorf("violation %v has no description", v)
+		}
+	}
+	expected := map[ViolationKind]Item{
+		ViolationColor:       root.Item,
+		ViolationRedRed:      root.Item,
+		ViolationBlackHeight: root.Item,
+		ViolationOrder:       Int(15),
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("violations %v, expect %v", violations, expected)
+	}
+	if err := c.CheckInvariants(); err != violations[0] {
+		t.Errorf("CheckInvariants() = %v, expect the first violation %v", err, violations[0])
+	}
+
+	// The clone does not share the nodes.
+	if v := rbt.Verify(); v != nil {
+		t.Errorf("original tree has violations %v", v)
+	}
+
+	c = rbt.Clone()
+	c.root.left.parent = c.root.right
+	c.count++
+	violations = c.Verify()
+	if len(violations) != 2 || violations[0].Kind != ViolationLink || violations[1].Kind != ViolationSize {
+		t.Errorf("violations %v, expect a link and a size", violations)
+	}
+	if violations[1].Item != nil {
+		t.Errorf("the count violation is about %v, expect the whole tree", violations[1].Item)
+	}
+	if s := ViolationKind(42).String(); s != "ViolationKind(42)" {
+		t.Errorf("unknown kind is %q", s)
+	}
+}
+
+func TestParentPointers(t *testing.T) {
+	rbt := New()
+	for _, v := range rand.Perm(1000) {
+		rbt.Insert(Int(v))
+	}
+	for _, v := range rand.Perm(1000)[:500] {
+		rbt.Delete(Int(v))
+	}
+
+	// Every node except the root is a child of its parent.
+	for _, n := range rbt.SliceAscend() {
+		if n == rbt.root {
+			if n.parent != rbt.NIL {
+				t.Errorf("root %v has a parent", n.Item)
+			}
+		} else if n.parent.left != n && n.parent.right != n {
+			t.Errorf("node %v is not a child of its parent %v", n.Item, n.parent.Item)
+		}
+	}
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetDebug(t *testing.T) {
+	rbt := New()
+	rbt.SetDebug(true)
+	for i := 0; i < 10000; i++ {
+		if v := Int(rand.Intn(1000)); rand.Intn(2) == 0 {
+			rbt.Insert(v)
+		} else {
+			rbt.Delete(v)
+		}
+	}
+
+	rbt.Insert(Int(1000))
+	rbt.NIL.color = RED
+	defer func() {
+		msg, _ := recover().(string)
+		if !strings.Contains(msg, "Delete(1000)") {
+			t.Errorf("Delete() on an invalid tree panics with %q", msg)
+		}
+	}()
+	rbt.Delete(Int(1000))
+}
+
+func TestSetDebugMutators(t *testing.T) {
+	tests := []struct {
+		op string
+		fn func(rbt *Rbtree)
+	}{
+		{"InsertOrGet(20)", func(rbt *Rbtree) { rbt.InsertOrGet(Int(20)) }},
+		{"GetOrInsert(20)", func(rbt *Rbtree) { rbt.GetOrInsert(Int(20)) }},
+		{"Replace(5)", func(rbt *Rbtree) { rbt.Replace(Int(5)) }},
+		{"DeleteAll(5)", func(rbt *Rbtree) { rbt.DeleteAll(Int(5)) }},
+		{"BulkDelete()", func(rbt *Rbtree) { rbt.BulkDelete([]Item{Int(1), Int(2), Int(3)}) }},
+		{"RangeDelete(0)", func(rbt *Rbtree) { rbt.RangeDelete(Int(0), Int(1)) }},
+		{"RangeDelete(0)", func(rbt *Rbtree) { rbt.RangeDelete(Int(0), Int(8)) }},
+		{"PopMin(0)", func(rbt *Rbtree) { rbt.PopMin() }},
+		{"PopMax(9)", func(rbt *Rbtree) { rbt.PopMax() }},
+		{"PopFirstN(0)", func(rbt *Rbtree) { rbt.PopFirstN(2) }},
+		{"BulkInsertSorted()", func(rbt *Rbtree) { rbt.BulkInsertSorted([]Item{Int(20)}) }},
+		{"BulkInsertSortedUnique()", func(rbt *Rbtree) { rbt.BulkInsertSortedUnique([]Item{Int(20)}) }},
+		{"Rebuild()", func(rbt *Rbtree) { rbt.Rebuild() }},
+		{"Join()", func(rbt *Rbtree) { rbt.Join(newIntTree(20, 21, 22)) }},
+	}
+
+	for _, test := range tests {
+		func() {
+			rbt := newIntTree(0, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+			rbt.SetDebug(true)
+			rbt.NIL.color = RED
+			defer func() {
+				msg, _ := recover().(string)
+				if !strings.Contains(msg, "after "+test.op) {
+					t.Errorf("%s on an invalid tree panics with %q", test.op, msg)
+				}
+			}()
+			test.fn(rbt)
+		}()
+	}
+
+	rbt := NewWithLRU()
+	rbt.Insert(Int(1))
+	rbt.Insert(Int(2))
+	rbt.SetDebug(true)
+	rbt.NIL.color = RED
+	defer func() {
+		msg, _ := recover().(string)
+		if !strings.Contains(msg, "after EvictLRU(1)") {
+			t.Errorf("EvictLRU() on an invalid tree panics with %q", msg)
+		}
+	}()
+	rbt.EvictLRU(1)
+}
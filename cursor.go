@@ -0,0 +1,160 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import "container/heap"
+
+// Cursor walks a Rbtree step by step without recursion, following the
+// parent pointers of the nodes. Unlike `Ascend` and `Descend`, the caller
+// drives the iteration and may pause or resume it at any time:
+//
+//	c := rbt.NewCursor()
+//	for n, ok := c.Next(); ok; n, ok = c.Next() {
+//	        fmt.Println(n.Item)
+//	}
+//
+// A cursor is either positioned on a node or off the tree. Being off the
+// tree counts as being both before the minimum and after the maximum, so
+// `Next` from there moves to the minimum and `Prev` moves to the maximum.
+// Hence `Next` and `Prev` can be interleaved freely, each one undoes the
+// other, even across the ends of the tree:
+//
+//	c.Seek(pivot)  // on x
+//	c.Prev()       // on the predecessor of x
+//	c.Next()       // on x again
+//
+// A cursor must not be used after the tree has been modified.
+type Cursor struct {
+	tree *Rbtree
+	node *Node
+}
+
+// NewCursor returns a cursor which is not positioned on any node yet.
+func (t *Rbtree) NewCursor() *Cursor {
+	return &Cursor{tree: t, node: t.NIL}
+}
+
+// SeekAscend returns a cursor positioned on the first node whose item is
+// greater or equal than pivot, so that the items from pivot on are pulled
+// one at a time by:
+//
+//	c := rbt.SeekAscend(pivot)
+//	for n, ok := c.Node(); ok; n, ok = c.Next() {
+//	        fmt.Println(n.Item)
+//	}
+func (t *Rbtree) SeekAscend(pivot Item) *Cursor {
+	c := t.NewCursor()
+	c.Seek(pivot)
+	return c
+}
+
+// Node returns the node the cursor is positioned on, it returns false if
+// the cursor is off the tree.
+func (c *Cursor) Node() (*Node, bool) {
+	if c.node == c.tree.NIL {
+		return nil, false
+	}
+	return c.node, true
+}
+
+// Next moves the cursor to the next node in ascending order and returns it.
+// It returns false once the cursor runs off the end of the tree.
+func (c *Cursor) Next() (*Node, bool) {
+	t := c.tree
+	if c.node == t.NIL {
+		c.node = t.min(t.root)
+	} else {
+		c.node = t.successor(c.node)
+	}
+	return c.Node()
+}
+
+// Prev moves the cursor to the previous node in ascending order and returns
+// it. It returns false once the cursor runs off the beginning of the tree.
+func (c *Cursor) Prev() (*Node, bool) {
+	t := c.tree
+	if c.node == t.NIL {
+		c.node = t.max(t.root)
+	} else {
+		c.node = t.predecessor(c.node)
+	}
+	return c.Node()
+}
+
+// Seek positions the cursor on the first node whose item is greater or
+// equal than pivot. The cursor is left off the tree if there is no such
+// node.
+func (c *Cursor) Seek(pivot Item) {
+	c.node = c.tree.ceiling(pivot)
+}
+
+// cursorHeap orders the cursors of MultiAscend by their current items, the
+// ones of the earlier trees go first among equal items.
+type cursorHeap struct {
+	less    func(a, b Item) bool
+	cursors []treeCursor
+}
+
+// treeCursor is a cursor of the tree at the position order of MultiAscend.
+type treeCursor struct {
+	*Cursor
+	order int
+}
+
+func (h *cursorHeap) Len() int { return len(h.cursors) }
+
+func (h *cursorHeap) Less(i, j int) bool {
+	a, b := h.cursors[i].node.Item, h.cursors[j].node.Item
+	if h.less(a, b) {
+		return true
+	}
+	if h.less(b, a) {
+		return false
+	}
+	return h.cursors[i].order < h.cursors[j].order
+}
+
+func (h *cursorHeap) Swap(i, j int) { h.cursors[i], h.cursors[j] = h.cursors[j], h.cursors[i] }
+
+func (h *cursorHeap) Push(x interface{}) { h.cursors = append(h.cursors, x.(treeCursor)) }
+
+func (h *cursorHeap) Pop() interface{} {
+	c := h.cursors[len(h.cursors)-1]
+	h.cursors = h.cursors[:len(h.cursors)-1]
+	return c
+}
+
+// MultiAscend will call iterator once for each element of all the trees in
+// ascending order, as if they were merged into one, which is done on the fly
+// by a heap of one cursor per tree. The items are compared the same way as
+// in the first tree, all the trees must order them the same way. Equal items
+// are visited in the order of their trees. It will stop whenever the
+// iterator returns false.
+func MultiAscend(trees []*Rbtree, iterator Iterator) {
+	if len(trees) == 0 {
+		return
+	}
+
+	h := &cursorHeap{less: trees[0].less}
+	for i, t := range trees {
+		c := t.NewCursor()
+		if _, ok := c.Next(); ok {
+			h.cursors = append(h.cursors, treeCursor{c, i})
+		}
+	}
+	heap.Init(h)
+
+	for h.Len() > 0 {
+		c := h.cursors[0]
+		if !iterator(c.node.Item) {
+			return
+		}
+		if _, ok := c.Next(); ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCursorEmpty(t *testing.T) {
+	c := New().NewCursor()
+
+	if n, ok := c.Next(); ok {
+		t.Errorf("Next() on empty tree = %v, expect nothing", n.Item)
+	}
+	if n, ok := c.Prev(); ok {
+		t.Errorf("Prev() on empty tree = %v, expect nothing", n.Item)
+	}
+	c.Seek(Int(1))
+	if n, ok := c.Node(); ok {
+		t.Errorf("Node() after Seek on empty tree = %v, expect nothing", n.Item)
+	}
+}
+
+func TestCursorNextPrev(t *testing.T) {
+	rbt := New()
+	for i := 0; i < 100; i++ {
+		rbt.Insert(Int(i))
+	}
+
+	var ret []Item
+	c := rbt.NewCursor()
+	for n, ok := c.Next(); ok; n, ok = c.Next() {
+		ret = append(ret, n.Item)
+	}
+	var expected []Item
+	for _, n := range rbt.SliceAscend() {
+		expected = append(expected, n.Item)
+	}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	ret = nil
+	c = rbt.NewCursor()
+	for n, ok := c.Prev(); ok; n, ok = c.Prev() {
+		ret = append(ret, n.Item)
+	}
+	expected = nil
+	for _, n := range rbt.SliceDescend() {
+		expected = append(expected, n.Item)
+	}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+}
+
+func TestCursorSeek(t *testing.T) {
+	rbt := New()
+	for i := 0; i < 20; i += 2 {
+		rbt.Insert(Int(i))
+	}
+
+	tests := []struct {
+		pivot    Int
+		expected Item
+	}{
+		{-1, Int(0)},
+		{0, Int(0)},
+		{5, Int(6)},
+		{6, Int(6)},
+		{18, Int(18)},
+	}
+
+	c := rbt.NewCursor()
+	for _, test := range tests {
+		c.Seek(test.pivot)
+		n, ok := c.Node()
+		if !ok || n.Item != test.expected {
+			t.Errorf("Seek(%v) positioned on %v, expect %v", test.pivot, n, test.expected)
+		}
+	}
+
+	c.Seek(Int(19))
+	if n, ok := c.Node(); ok {
+		t.Errorf("Seek(19) positioned on %v, expect nothing", n.Item)
+	}
+
+	c.Seek(Int(7))
+	var ret []Item
+	for n, ok := c.Node(); ok; n, ok = c.Next() {
+		ret = append(ret, n.Item)
+	}
+	expected := []Item{Int(8), Int(10), Int(12), Int(14), Int(16), Int(18)}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+}
+
+func TestCursorZigZag(t *testing.T) {
+	rbt := New()
+	for i := 1; i <= 5; i++ {
+		rbt.Insert(Int(i))
+	}
+
+	c := rbt.NewCursor()
+	c.Seek(Int(3))
+
+	// Each step is the movement followed by the expected item, 0 for being
+	// off the tree.
+	steps := []struct {
+		next     bool
+		expected Int
+	}{
+		{false, 2}, {true, 3}, {true, 4}, {false, 3},
+		{true, 4}, {true, 5}, {true, 0}, {false, 5},
+		{true, 0}, {true, 1}, {false, 0}, {false, 5},
+		{false, 4}, {true, 5}, {false, 4}, {false, 3},
+		{false, 2}, {false, 1}, {false, 0}, {true, 1},
+	}
+
+	for i, step := range steps {
+		var n *Node
+		var ok bool
+		if step.next {
+			n, ok = c.Next()
+		} else {
+			n, ok = c.Prev()
+		}
+
+		if step.expected == 0 {
+			if ok {
+				t.Fatalf("step %d: expect to be off the tree but got %v", i, n.Item)
+			}
+			continue
+		}
+		if !ok || n.Item != step.expected {
+			t.Fatalf("step %d: expect %v but got %v", i, step.expected, n)
+		}
+		if cur, _ := c.Node(); cur != n {
+			t.Fatalf("step %d: Node() does not agree with the movement", i)
+		}
+	}
+}
+
+func TestSeekAscend(t *testing.T) {
+	rbt := New()
+	for i := 1; i <= 100; i++ {
+		rbt.Insert(Int(i))
+	}
+
+	var ret []Item
+	c := rbt.SeekAscend(Int(50))
+	for n, ok := c.Node(); ok && len(ret) < 5; n, ok = c.Next() {
+		ret = append(ret, n.Item)
+	}
+	expected := []Item{Int(50), Int(51), Int(52), Int(53), Int(54)}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	// The cursor stays where the caller left it.
+	if n, ok := c.Node(); !ok || n.Item != Int(55) {
+		t.Errorf("Node() = %v, %v, expect 55, true", n, ok)
+	}
+
+	if n, ok := rbt.SeekAscend(Int(0)).Node(); !ok || n.Item != Int(1) {
+		t.Errorf("SeekAscend(0).Node() = %v, %v, expect 1, true", n, ok)
+	}
+	if n, ok := rbt.SeekAscend(Int(101)).Node(); ok {
+		t.Errorf("SeekAscend(101).Node() = %v, expect nothing", n.Item)
+	}
+}
+
+func TestMultiAscend(t *testing.T) {
+	trees := []*Rbtree{
+		newIntTree(intRange(0, 30, 3)...),
+		newIntTree(intRange(10, 20, 1)...),
+		New(),
+		newIntTree(intRange(0, 40, 5)...),
+	}
+
+	var ret []Item
+	MultiAscend(trees, func(i Item) bool {
+		ret = append(ret, i)
+		return true
+	})
+
+	var expected []int
+	for _, rbt := range trees {
+		for _, item := range items(rbt) {
+			expected = append(expected, int(item.(Int)))
+		}
+	}
+	sort.Ints(expected)
+	if len(ret) != len(expected) {
+		t.Fatalf("MultiAscend visited %d items, expect %d", len(ret), len(expected))
+	}
+	for i, v := range expected {
+		if ret[i] != Int(v) {
+			t.Errorf("item %d is %v, expect %v", i, ret[i], v)
+		}
+	}
+
+	ret = nil
+	MultiAscend(trees, func(i Item) bool {
+		ret = append(ret, i)
+		return len(ret) < 4
+	})
+	if expected := []Item{Int(0), Int(0), Int(3), Int(5)}; !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	MultiAscend(nil, func(i Item) bool {
+		t.Errorf("unexpected %v without trees", i)
+		return true
+	})
+}
@@ -0,0 +1,149 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dotWriter remembers the first error so that the nodes can be written
+// without checking each Fprintf.
+type dotWriter struct {
+	w   io.Writer
+	err error
+	ids int
+
+	nilLeaves bool
+}
+
+func (d *dotWriter) printf(format string, args ...interface{}) {
+	if d.err == nil {
+		_, d.err = fmt.Fprintf(d.w, format, args...)
+	}
+}
+
+// WriteDOTOptions tunes the output of WriteDOTWithOptions.
+type WriteDOTOptions struct {
+	// NilLeaves draws the NIL leaves as small black boxes, which shows the
+	// black heights at the cost of one more node and edge per leaf.
+	NilLeaves bool
+}
+
+// WriteDOT writes the tree as a Graphviz digraph, which can be rendered by
+// e.g. `dot -Tpng`. Each node is labeled with its item and filled with its
+// color, the NIL leaves are drawn as small black boxes. Use
+// WriteDOTWithOptions to leave them out.
+func (t *Rbtree) WriteDOT(w io.Writer) error {
+	return t.WriteDOTWithOptions(w, WriteDOTOptions{NilLeaves: true})
+}
+
+// WriteDOTWithOptions is the same as WriteDOT but the output is tuned by
+// opts.
+func (t *Rbtree) WriteDOTWithOptions(w io.Writer, opts WriteDOTOptions) error {
+	d := &dotWriter{w: w, nilLeaves: opts.NilLeaves}
+	d.printf("digraph rbtree {\n")
+	d.printf("\tnode [style=filled, fontcolor=white];\n")
+	t.writeDOT(d, t.root)
+	d.printf("}\n")
+	return d.err
+}
+
+// writeDOT writes the subtree rooted at x and returns the id of x, or an
+// empty id if x is a NIL leaf which is not drawn.
+func (t *Rbtree) writeDOT(d *dotWriter, x *Node) string {
+	if x == t.NIL && !d.nilLeaves {
+		return ""
+	}
+
+	d.ids++
+	if x == t.NIL {
+		id := fmt.Sprintf("nil%d", d.ids)
+		d.printf("\t%s [shape=box, width=0.2, height=0.2, label=\"\", fillcolor=black];\n", id)
+		return id
+	}
+
+	id := fmt.Sprintf("n%d", d.ids)
+	color := "black"
+	if x.color == RED {
+		color = "red"
+	}
+	d.printf("\t%s [label=%q, fillcolor=%s];\n", id, fmt.Sprint(x.Item), color)
+	for _, child := range []*Node{x.left, x.right} {
+		if c := t.writeDOT(d, child); c != "" {
+			d.printf("\t%s -> %s;\n", id, c)
+		}
+	}
+	return id
+}
+
+// String renders the tree sideways, i.e. the root is on the very left and
+// the right subtrees are above the left ones, each node is indented by its
+// depth and marked by `R` or `B` for its color.
+func (t *Rbtree) String() string {
+	if t.root == t.NIL {
+		return "<empty>"
+	}
+
+	var b strings.Builder
+	t.format(&b, t.root, 0)
+	return b.String()
+}
+
+func (t *Rbtree) format(b *strings.Builder, x *Node, depth int) {
+	if x == t.NIL {
+		return
+	}
+
+	t.format(b, x.right, depth+1)
+	color := "B"
+	if x.color == RED {
+		color = "R"
+	}
+	fmt.Fprintf(b, "%s%s %v\n", strings.Repeat("    ", depth), color, x.Item)
+	t.format(b, x.left, depth+1)
+}
+
+// WalkNodes calls fn once for each item in ascending order together with
+// the color of its node, "red" or "black", and the depth of the node, which
+// is 0 for the root. It will stop whenever fn returns false.
+func (t *Rbtree) WalkNodes(fn func(item Item, color string, depth int) bool) {
+	t.walkNodes(t.root, 0, fn)
+}
+
+func (t *Rbtree) walkNodes(x *Node, depth int, fn func(item Item, color string, depth int) bool) bool {
+	if x == t.NIL {
+		return true
+	}
+
+	return t.walkNodes(x.left, depth+1, fn) &&
+		fn(x.Item, x.Color(), depth) &&
+		t.walkNodes(x.right, depth+1, fn)
+}
+
+// SearchPath returns the items visited from the root down to the node of
+// the item equal to key, which is the last one, so that it shows why a
+// lookup went where it did. If there is no such item, the path goes down to
+// the node under which key would be inserted. The path stops at the first
+// equal item even if the tree allows duplicates.
+func (t *Rbtree) SearchPath(key Item) []Item {
+	if key == nil {
+		return nil
+	}
+
+	var path []Item
+	for x := t.root; x != t.NIL; {
+		path = append(path, x.Item)
+		if t.less(x.Item, key) {
+			x = x.right
+		} else if t.less(key, x.Item) {
+			x = x.left
+		} else {
+			break
+		}
+	}
+	return path
+}
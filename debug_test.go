@@ -0,0 +1,201 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestWriteDOT(t *testing.T) {
+	rbt := New()
+	rbt.Insert(Int(2))
+	rbt.Insert(Int(1))
+	rbt.Insert(Int(3))
+
+	var buf bytes.Buffer
+	if err := rbt.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	expected := []string{
+		"digraph rbtree {\n",
+		"\tn1 [label=\"2\", fillcolor=black];\n",
+		"\tn2 [label=\"1\", fillcolor=red];\n",
+		"\tn5 [label=\"3\", fillcolor=red];\n",
+		"\tn1 -> n2;\n",
+		"\tn1 -> n5;\n",
+		"\tn2 -> nil3;\n",
+		"\tn5 -> nil7;\n",
+		"\tnil4 [shape=box, width=0.2, height=0.2, label=\"\", fillcolor=black];\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line) {
+			t.Errorf("expect %q in the output:\n%s", line, out)
+		}
+	}
+	if !strings.HasSuffix(out, "}\n") {
+		t.Errorf("the digraph is not closed:\n%s", out)
+	}
+}
+
+func TestWriteDOTWithoutNilLeaves(t *testing.T) {
+	rbt := New()
+	rbt.Insert(Int(2))
+	rbt.Insert(Int(1))
+	rbt.Insert(Int(3))
+
+	var buf bytes.Buffer
+	if err := rbt.WriteDOTWithOptions(&buf, WriteDOTOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "digraph rbtree {\n" +
+		"\tnode [style=filled, fontcolor=white];\n" +
+		"\tn1 [label=\"2\", fillcolor=black];\n" +
+		"\tn2 [label=\"1\", fillcolor=red];\n" +
+		"\tn1 -> n2;\n" +
+		"\tn3 [label=\"3\", fillcolor=red];\n" +
+		"\tn1 -> n3;\n" +
+		"}\n"
+	if out := buf.String(); out != expected {
+		t.Errorf("expected\n%s\nbut got\n%s", expected, out)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestWriteDOTError(t *testing.T) {
+	rbt := New()
+	rbt.Insert(Int(1))
+
+	if err := rbt.WriteDOT(failingWriter{}); err == nil {
+		t.Errorf("expect the error of the writer")
+	}
+}
+
+func TestString(t *testing.T) {
+	rbt := New()
+	if s := rbt.String(); s != "<empty>" {
+		t.Errorf("expected <empty> but got %q", s)
+	}
+
+	for i := 1; i <= 7; i++ {
+		rbt.Insert(Int(i))
+	}
+
+	golden := filepath.Join("testdata", "string.golden")
+	if *update {
+		if err := os.WriteFile(golden, []byte(rbt.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := rbt.String(); s != string(expected) {
+		t.Errorf("expected\n%s\nbut got\n%s", expected, s)
+	}
+}
+
+func TestWalkNodes(t *testing.T) {
+	New().WalkNodes(func(item Item, color string, depth int) bool {
+		t.Errorf("unexpected %v in empty tree", item)
+		return true
+	})
+
+	rbt := New()
+	for i := 1; i <= 7; i++ {
+		rbt.Insert(Int(i))
+	}
+
+	var ret []Item
+	rbt.WalkNodes(func(item Item, color string, depth int) bool {
+		ret = append(ret, item)
+		if item == rbt.root.Item && (depth != 0 || color != "black") {
+			t.Errorf("root %v has depth %d and color %q, expect 0 and black", item, depth, color)
+		}
+		if depth == 0 && item != rbt.root.Item {
+			t.Errorf("%v has depth 0 but is not the root", item)
+		}
+		if depth >= rbt.Height() {
+			t.Errorf("%v has depth %d, expect less than the height %d", item, depth, rbt.Height())
+		}
+		return true
+	})
+	if expected := items(rbt); !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	visited := 0
+	rbt.WalkNodes(func(Item, string, int) bool {
+		visited++
+		return visited < 3
+	})
+	if visited != 3 {
+		t.Errorf("WalkNodes visited %d nodes after stopping, expect %d", visited, 3)
+	}
+}
+
+func TestSearchPath(t *testing.T) {
+	if path := New().SearchPath(Int(1)); len(path) != 0 {
+		t.Errorf("SearchPath(1) of empty tree = %v, expect nothing", path)
+	}
+
+	rbt := New()
+	for _, v := range rand.Perm(1000) {
+		rbt.Insert(Int(v * 2))
+	}
+	root, _ := rbt.Root()
+
+	for _, key := range []Int{0, 1, 500, 777, 1998, 1999, -1} {
+		path := rbt.SearchPath(key)
+		if len(path) == 0 || len(path) > rbt.Height() || path[0] != root.Item {
+			t.Fatalf("SearchPath(%v) = %v, expect a path from the root %v of at most %d items", key, path, root.Item, rbt.Height())
+		}
+		if present := key >= 0 && key%2 == 0; present != (path[len(path)-1] == key) {
+			t.Errorf("SearchPath(%v) ends at %v", key, path[len(path)-1])
+		}
+
+		// Each item of the path is a child of the previous one, on the side
+		// of the key.
+		x := root
+		for i, item := range path[1:] {
+			if key.Less(path[i]) {
+				x = x.Left()
+			} else {
+				x = x.Right()
+			}
+			if x == nil || x.Item != item {
+				t.Fatalf("SearchPath(%v) = %v, %v is not a child of %v", key, path, item, path[i])
+			}
+		}
+		if path[len(path)-1] != key {
+			if key.Less(x.Item) && x.Left() != nil || x.Item.Less(key) && x.Right() != nil {
+				t.Errorf("SearchPath(%v) = %v, expect it to go down to a leaf", key, path)
+			}
+		}
+	}
+
+	if path := rbt.SearchPath(nil); path != nil {
+		t.Errorf("SearchPath(nil) = %v, expect nothing", path)
+	}
+}
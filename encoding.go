@@ -0,0 +1,291 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNoDecoder is returned by UnmarshalJSON if SetJSONDecoder has not been
+// called on the tree.
+var ErrNoDecoder = errors.New("rbtree: no decoder for the items")
+
+// ErrNoCodec is returned by Save and Load if SetCodec has not been called on
+// the tree.
+var ErrNoCodec = errors.New("rbtree: no codec for the items")
+
+// ErrNotSnapshot is returned by Load if the data does not start with the
+// magic header written by Save.
+var ErrNotSnapshot = errors.New("rbtree: not a snapshot, bad magic header")
+
+// SetJSONDecoder registers the function which builds an item from its JSON
+// encoding for UnmarshalJSON, since there is no way to know the concrete
+// type of the items otherwise.
+func (t *Rbtree) SetJSONDecoder(decode func(data []byte) (Item, error)) {
+	t.decodeJSON = decode
+}
+
+// MarshalJSON encodes the tree as a JSON array of the items in ascending
+// order.
+func (t *Rbtree) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.appendItems(make([]Item, 0, t.count), t.root))
+}
+
+// UnmarshalJSON replaces the items of the tree by the ones in the JSON array,
+// each of them is built by the decoder registered with SetJSONDecoder.
+func (t *Rbtree) UnmarshalJSON(data []byte) error {
+	if t.decodeJSON == nil {
+		return ErrNoDecoder
+	}
+
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return err
+	}
+
+	items := make([]Item, 0, len(raws))
+	for _, raw := range raws {
+		item, err := t.decodeJSON(raw)
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+	}
+
+	t.Clear()
+	for _, item := range items {
+		t.Insert(item)
+	}
+	return nil
+}
+
+// GobEncode encodes the items of the tree in ascending order. The concrete
+// types of the items have to be registered by gob.Register before either
+// encoding or decoding, e.g.
+//
+//	gob.Register(rbtree.Int(0))
+func (t *Rbtree) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	items := t.appendItems(make([]Item, 0, t.count), t.root)
+	if err := gob.NewEncoder(&buf).Encode(items); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode replaces the items of the tree by the ones encoded by GobEncode.
+func (t *Rbtree) GobDecode(data []byte) error {
+	var items []Item
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&items); err != nil {
+		return err
+	}
+
+	// The decoder may hand us a zero Rbtree.
+	if t.NIL == nil {
+		t.Init()
+	}
+
+	t.Clear()
+	for _, item := range items {
+		t.Insert(item)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingReader counts the bytes read from r, it reads byte by byte for
+// ReadByte so that nothing is read ahead of the items.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(c, b[:]); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// WriteItemsTo writes the number of items as an uvarint and then each item
+// in ascending order by enc, which keeps the encoding of the items under
+// the control of the caller. It returns the number of bytes written.
+//
+// Unlike MarshalJSON, the items are streamed to w rather than encoded in
+// memory all at once, which suits the large trees.
+func (t *Rbtree) WriteItemsTo(w io.Writer, enc func(w io.Writer, item Item) error) (int64, error) {
+	cw := &countingWriter{w: w}
+	if _, err := cw.Write(binary.AppendUvarint(nil, uint64(t.count))); err != nil {
+		return cw.n, err
+	}
+
+	var err error
+	t.walk(t.root, func(i Item) bool {
+		err = enc(cw, i)
+		return err == nil
+	})
+	return cw.n, err
+}
+
+// ReadItemsFrom replaces the items of the tree by the ones written by
+// WriteItemsTo, each of them is read by dec. It returns the number of bytes
+// read, the tree is left unchanged if there is an error. The reader given to
+// dec also implements io.ByteReader, e.g. for binary.ReadVarint, and never
+// reads ahead of the items.
+func (t *Rbtree) ReadItemsFrom(r io.Reader, dec func(r io.Reader) (Item, error)) (int64, error) {
+	cr := &countingReader{r: r}
+	count, err := binary.ReadUvarint(cr)
+	if err != nil {
+		return cr.n, err
+	}
+
+	// Do not trust the header too much before the items are there.
+	items := make([]Item, 0, min(count, 1024))
+	for i := uint64(0); i < count; i++ {
+		item, err := dec(cr)
+		if err != nil {
+			return cr.n, err
+		}
+		items = append(items, item)
+	}
+
+	t.Clear()
+	for _, item := range items {
+		t.Insert(item)
+	}
+	return cr.n, nil
+}
+
+// Codec encodes and decodes the items for Save and Load, so that the format
+// of the items is up to the caller.
+type Codec interface {
+	EncodeItem(item Item) ([]byte, error)
+	DecodeItem(data []byte) (Item, error)
+}
+
+// SetCodec registers the codec of the items for Save and Load.
+func (t *Rbtree) SetCodec(c Codec) {
+	t.codec = c
+}
+
+// snapshotMagic starts every snapshot written by Save, it is followed by
+// the version of the format.
+const (
+	snapshotMagic   = "RBTS"
+	snapshotVersion = 1
+)
+
+// Save writes a snapshot of the tree to w, which is the magic header and
+// the version of the format, then the number of items as an uvarint and
+// each item in ascending order, encoded by the codec registered with
+// SetCodec and prefixed by its length as an uvarint.
+func (t *Rbtree) Save(w io.Writer) error {
+	if t.codec == nil {
+		return ErrNoCodec
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(snapshotMagic)
+	bw.WriteByte(snapshotVersion)
+	bw.Write(binary.AppendUvarint(nil, uint64(t.count)))
+
+	var err error
+	t.walk(t.root, func(i Item) bool {
+		var data []byte
+		if data, err = t.codec.EncodeItem(i); err != nil {
+			return false
+		}
+		bw.Write(binary.AppendUvarint(nil, uint64(len(data))))
+		_, err = bw.Write(data)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Load replaces the items of the tree by the ones of a snapshot written by
+// Save, each of them is decoded by the codec registered with SetCodec. The
+// items are in order already, so the tree is built in O(n). It returns
+// ErrNotSnapshot if the magic header is wrong, and the tree is left
+// unchanged if there is any error.
+func (t *Rbtree) Load(r io.Reader) error {
+	if t.codec == nil {
+		return ErrNoCodec
+	}
+
+	br := &countingReader{r: r}
+	var header [len(snapshotMagic) + 1]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return err
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return ErrNotSnapshot
+	}
+	if v := header[len(snapshotMagic)]; v != snapshotVersion {
+		return fmt.Errorf("rbtree: snapshot version %d is not supported", v)
+	}
+
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return err
+	}
+
+	// Do not trust the lengths too much before the data is there.
+	items := make([]Item, 0, min(count, 1024))
+	for i := uint64(0); i < count; i++ {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(io.LimitReader(br, int64(n)))
+		if err != nil {
+			return err
+		}
+		if uint64(len(data)) != n {
+			return io.ErrUnexpectedEOF
+		}
+
+		item, err := t.codec.DecodeItem(data)
+		if err != nil {
+			return err
+		}
+		if k := len(items); item == nil || k > 0 && (t.less(item, items[k-1]) || !t.dup && !t.less(items[k-1], item)) {
+			return ErrNotSorted
+		}
+		items = append(items, item)
+	}
+
+	t.Clear()
+	t.build(items)
+	t.verify("Load", nil)
+	return nil
+}
@@ -0,0 +1,263 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decodeInt(data []byte) (Item, error) {
+	var i int
+	err := json.Unmarshal(data, &i)
+	return Int(i), err
+}
+
+func TestJSON(t *testing.T) {
+	rbt := New()
+	for _, v := range rand.Perm(20) {
+		rbt.Insert(Int(v * 2))
+	}
+
+	data, err := json.Marshal(rbt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[0,2,4,6,8,10,12,14,16,18,20,22,24,26,28,30,32,34,36,38]"
+	if string(data) != expected {
+		t.Errorf("expected %s but got %s", expected, data)
+	}
+
+	decoded := New()
+	decoded.Insert(Int(1))
+	decoded.SetJSONDecoder(decodeInt)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	var ret, want []Item
+	for _, n := range decoded.SliceAscend() {
+		ret = append(ret, n.Item)
+	}
+	for _, n := range rbt.SliceAscend() {
+		want = append(want, n.Item)
+	}
+	if !reflect.DeepEqual(ret, want) {
+		t.Errorf("expected %v but got %v", want, ret)
+	}
+
+	if err := json.Unmarshal([]byte("[]"), New()); err != ErrNoDecoder {
+		t.Errorf("expected %v but got %v", ErrNoDecoder, err)
+	}
+	if err := json.Unmarshal([]byte(`["x"]`), decoded); err == nil {
+		t.Errorf("expected an error for a bad item")
+	}
+	if decoded.Len() != 20 {
+		t.Errorf("tree.Len() = %d, expect %d", decoded.Len(), 20)
+	}
+}
+
+func TestGob(t *testing.T) {
+	gob.Register(Int(0))
+
+	rbt := New()
+	for _, v := range rand.Perm(100) {
+		rbt.Insert(Int(v))
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(rbt); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded *Rbtree
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	checkSize(t, decoded, decoded.root)
+
+	var ret, expected []Item
+	for _, n := range decoded.SliceAscend() {
+		ret = append(ret, n.Item)
+	}
+	for _, n := range rbt.SliceAscend() {
+		expected = append(expected, n.Item)
+	}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+}
+
+func writeVarint(w io.Writer, item Item) error {
+	_, err := w.Write(binary.AppendVarint(nil, int64(item.(Int))))
+	return err
+}
+
+func readVarint(r io.Reader) (Item, error) {
+	v, err := binary.ReadVarint(r.(io.ByteReader))
+	return Int(v), err
+}
+
+func TestItemsToAndFrom(t *testing.T) {
+	rbt := New()
+	for _, v := range rand.Perm(1000) {
+		rbt.Insert(Int(v - 500))
+	}
+
+	var buf bytes.Buffer
+	n, err := rbt.WriteItemsTo(&buf, writeVarint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteItemsTo() = %d, but %d bytes are written", n, buf.Len())
+	}
+	size := buf.Len()
+
+	// Some more data follows the items, which must be left unread.
+	buf.WriteString("tail")
+
+	other := newIntTree(1, 2, 3)
+	if n, err := other.ReadItemsFrom(&buf, readVarint); err != nil || n != int64(size) {
+		t.Fatalf("ReadItemsFrom() = %d, %v, expect %d, nil", n, err, size)
+	}
+	if err := other.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(items(other), items(rbt)) {
+		t.Errorf("expected %v but got %v", items(rbt), items(other))
+	}
+	if buf.String() != "tail" {
+		t.Errorf("ReadItemsFrom() left %q, expect %q", buf.String(), "tail")
+	}
+
+	// A truncated stream leaves the tree unchanged.
+	buf.Reset()
+	newIntTree(1, 2, 3).WriteItemsTo(&buf, writeVarint)
+	buf.Truncate(buf.Len() - 1)
+	if _, err := other.ReadItemsFrom(&buf, readVarint); err == nil {
+		t.Errorf("ReadItemsFrom() succeeded on a truncated stream")
+	}
+	if other.Len() != rbt.Len() {
+		t.Errorf("tree.Len() = %d, expect %d", other.Len(), rbt.Len())
+	}
+
+	if _, err := rbt.WriteItemsTo(failingWriter{}, writeVarint); err == nil {
+		t.Errorf("WriteItemsTo() succeeded on a failing writer")
+	}
+}
+
+// intCodec encodes the Int items as varints.
+type intCodec struct{}
+
+func (intCodec) EncodeItem(item Item) ([]byte, error) {
+	return binary.AppendVarint(nil, int64(item.(Int))), nil
+}
+
+func (intCodec) DecodeItem(data []byte) (Item, error) {
+	v, n := binary.Varint(data)
+	if n != len(data) {
+		return nil, errors.New("bad varint")
+	}
+	return Int(v), nil
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	for _, rbt := range []*Rbtree{New(), NewWithDuplicates(true)} {
+		rbt.SetCodec(intCodec{})
+		for i := 0; i < 1000; i++ {
+			rbt.Insert(Int(rand.Intn(500) - 250))
+		}
+
+		var buf bytes.Buffer
+		if err := rbt.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(buf.Bytes(), []byte("RBTS\x01")) {
+			t.Errorf("snapshot starts with %q, expect the magic and the version", buf.Bytes()[:5])
+		}
+
+		other := rbt.emptyClone()
+		other.InsertMany(Int(1000), Int(1001))
+		if err := other.Load(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := other.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(items(other), items(rbt)) {
+			t.Errorf("expected %v but got %v", items(rbt), items(other))
+		}
+	}
+
+	empty := New()
+	empty.SetCodec(intCodec{})
+	var buf bytes.Buffer
+	if err := empty.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rbt := newIntTree(1, 2, 3)
+	rbt.SetCodec(intCodec{})
+	if err := rbt.Load(&buf); err != nil || rbt.Len() != 0 {
+		t.Errorf("Load() of empty snapshot = %v with %d items, expect nil with none", err, rbt.Len())
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if err := New().Save(io.Discard); err != ErrNoCodec {
+		t.Errorf("expected %v but got %v", ErrNoCodec, err)
+	}
+	if err := New().Load(bytes.NewReader(nil)); err != ErrNoCodec {
+		t.Errorf("expected %v but got %v", ErrNoCodec, err)
+	}
+
+	src := newIntTree(1, 2, 3)
+	src.SetCodec(intCodec{})
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := buf.Bytes()
+
+	if err := src.Save(failingWriter{}); err == nil {
+		t.Errorf("Save() succeeded on a failing writer")
+	}
+
+	corrupt := func(fn func(data []byte) []byte) []byte {
+		return fn(append([]byte(nil), snapshot...))
+	}
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"magic", corrupt(func(d []byte) []byte { d[0] = 'X'; return d }), ErrNotSnapshot.Error()},
+		{"json", []byte("[1,2,3]"), ErrNotSnapshot.Error()},
+		{"version", corrupt(func(d []byte) []byte { d[4] = 2; return d }), "version 2"},
+		{"truncated header", snapshot[:3], "EOF"},
+		{"truncated item", snapshot[:len(snapshot)-1], "EOF"},
+		{"order", corrupt(func(d []byte) []byte { d[7], d[9] = d[9], d[7]; return d }), ErrNotSorted.Error()},
+	}
+
+	for _, test := range tests {
+		rbt := newIntTree(7, 8)
+		rbt.SetCodec(intCodec{})
+		err := rbt.Load(bytes.NewReader(test.data))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Load() of bad %s = %v, expect %q", test.name, err, test.err)
+		}
+		if !reflect.DeepEqual(items(rbt), []Item{Int(7), Int(8)}) {
+			t.Errorf("Load() of bad %s changed the tree to %v", test.name, items(rbt))
+		}
+	}
+}
@@ -29,7 +29,7 @@ func main() {
 		m++
 	}
 
-	rbt.Ascend(rbt.Min(), print)
+	rbt.AscendAll(print)
 }
 
 func print(item rbtree.Item) bool {
@@ -16,7 +16,7 @@ func main() {
 	rbt.Insert(rbtree.String("Hello"))
 	rbt.Insert(rbtree.String("World"))
 
-	rbt.Ascend(rbt.Min(), print)
+	rbt.AscendAll(print)
 }
 
 func print(item rbtree.Item) bool {
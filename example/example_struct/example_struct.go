@@ -58,7 +58,8 @@ func main() {
 	}
 
 	// var4 and var5 were expected
-	rbt.Ascend(rbt.Get(tmp), print)
+	pivot, _ := rbt.Get(tmp)
+	rbt.Ascend(pivot, print)
 }
 
 func print(item rbtree.Item) bool {
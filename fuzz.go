@@ -0,0 +1,108 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import (
+	"fmt"
+	"sort"
+)
+
+// OpKind is the kind of an Op.
+type OpKind int
+
+// The kinds of the operations replayed by ApplyOps.
+const (
+	OpInsert OpKind = iota
+	OpDelete
+	OpGet
+)
+
+func (k OpKind) String() string {
+	switch k {
+	case OpInsert:
+		return "Insert"
+	case OpDelete:
+		return "Delete"
+	case OpGet:
+		return "Get"
+	}
+	return fmt.Sprintf("OpKind(%d)", int(k))
+}
+
+// Op is an operation of the item on a tree.
+type Op struct {
+	Kind OpKind
+	Item Item
+}
+
+// ApplyOps applies the operations to the tree one after another and compares
+// the results with a sorted slice of the items, which is updated the same
+// way. It returns an error describing the first difference or violation of
+// the invariants, so that a fuzz test can simply replay random operations.
+//
+// The items must be comparable with ==, and the tree must not have an equal
+// function set by SetEqual since the model only knows about the order.
+func (t *Rbtree) ApplyOps(ops []Op) error {
+	model := t.appendItems(nil, t.root)
+
+	for i, op := range ops {
+		k, found := len(model), false
+		if op.Item != nil {
+			k = sort.Search(len(model), func(j int) bool { return !t.less(model[j], op.Item) })
+			found = k < len(model) && !t.less(op.Item, model[k])
+		}
+
+		var got, expected Item
+		switch op.Kind {
+		case OpInsert:
+			t.Insert(op.Item)
+			if op.Item == nil {
+				break
+			}
+			if found && !t.dup {
+				model[k] = op.Item
+				break
+			}
+			// The new item goes after the equal ones.
+			for k < len(model) && !t.less(op.Item, model[k]) {
+				k++
+			}
+			model = append(model, nil)
+			copy(model[k+1:], model[k:])
+			model[k] = op.Item
+		case OpDelete:
+			got = t.Delete(op.Item)
+			if found {
+				expected = model[k]
+				model = append(model[:k], model[k+1:]...)
+			}
+		case OpGet:
+			got, _ = t.Get(op.Item)
+			if found {
+				expected = model[k]
+			}
+		default:
+			return fmt.Errorf("rbtree: op %d has an unknown kind %v", i, op.Kind)
+		}
+
+		if got != expected {
+			return fmt.Errorf("rbtree: op %d %v(%v) = %v, expect %v", i, op.Kind, op.Item, got, expected)
+		}
+		if err := t.CheckInvariants(); err != nil {
+			return fmt.Errorf("rbtree: op %d %v(%v): %v", i, op.Kind, op.Item, err)
+		}
+		if t.count != len(model) {
+			return fmt.Errorf("rbtree: op %d %v(%v): Len() = %d, expect %d", i, op.Kind, op.Item, t.count, len(model))
+		}
+	}
+
+	items := t.appendItems(make([]Item, 0, t.count), t.root)
+	for i := range items {
+		if items[i] != model[i] {
+			return fmt.Errorf("rbtree: item %d is %v, expect %v", i, items[i], model[i])
+		}
+	}
+	return nil
+}
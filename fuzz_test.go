@@ -0,0 +1,59 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import (
+	"strings"
+	"testing"
+)
+
+// decodeOps turns every two bytes of data into an operation of a small
+// integer, so that the same items come up again and again.
+func decodeOps(data []byte) []Op {
+	var ops []Op
+	for ; len(data) >= 2; data = data[2:] {
+		ops = append(ops, Op{Kind: OpKind(data[0] % 3), Item: Int(data[1] % 64)})
+	}
+	return ops
+}
+
+func FuzzRbtree(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0, 1, 0, 2, 0, 3, 2, 2, 1, 2, 2, 2})
+	f.Add([]byte{0, 5, 0, 5, 1, 5, 1, 5, 1, 5})
+	f.Add([]byte("the quick brown fox jumps over the lazy dog"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ops := decodeOps(data)
+		if err := New().ApplyOps(ops); err != nil {
+			t.Fatal(err)
+		}
+		if err := NewWithDuplicates(true).ApplyOps(ops); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestApplyOps(t *testing.T) {
+	rbt := newIntTree(1, 2, 3)
+	ops := []Op{
+		{OpInsert, Int(4)},
+		{OpDelete, Int(2)},
+		{OpGet, Int(2)},
+		{OpGet, Int(3)},
+		{OpInsert, nil},
+		{OpDelete, nil},
+	}
+	if err := rbt.ApplyOps(ops); err != nil {
+		t.Fatal(err)
+	}
+	if rbt.Len() != 3 || rbt.Contains(Int(2)) {
+		t.Errorf("tree.Len() = %d, expect %d without 2", rbt.Len(), 3)
+	}
+
+	if err := rbt.ApplyOps([]Op{{OpKind(42), Int(1)}}); err == nil || !strings.Contains(err.Error(), "OpKind(42)") {
+		t.Errorf("ApplyOps() = %v, expect an unknown kind", err)
+	}
+}
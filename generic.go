@@ -0,0 +1,134 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import "iter"
+
+// Tree is a type-safe wrapper of Rbtree, the values are ordered by the less
+// function given to NewTree so that neither a Less method nor the type
+// assertions in the iterators are needed.
+type Tree[T any] struct {
+	tree *Rbtree
+}
+
+// value carries a T in the underlying Rbtree.
+type value[T any] struct {
+	v T
+}
+
+// Less is only there to make value an Item, the underlying Rbtree orders the
+// values by its comparator instead.
+func (x value[T]) Less(than Item) bool {
+	panic("rbtree: Less called on a value of a Tree")
+}
+
+// NewTree returns an initialized Tree which orders the values by less.
+func NewTree[T any](less func(a, b T) bool) *Tree[T] {
+	return &Tree[T]{tree: NewWithComparator(func(a, b Item) int {
+		x, y := a.(value[T]).v, b.(value[T]).v
+		if less(x, y) {
+			return -1
+		}
+		if less(y, x) {
+			return 1
+		}
+		return 0
+	})}
+}
+
+func (t *Tree[T]) wrap(v T) value[T] {
+	return value[T]{v}
+}
+
+// Len returns number of values in the tree.
+func (t *Tree[T]) Len() int { return t.tree.Len() }
+
+// Insert inserts the value into the tree, it returns false if it has
+// replaced an equal value instead.
+func (t *Tree[T]) Insert(v T) bool {
+	return t.tree.Insert(t.wrap(v))
+}
+
+// Delete deletes the value equal to v from the tree and returns it, it
+// returns false if there is no such value.
+func (t *Tree[T]) Delete(v T) (T, bool) {
+	item := t.tree.Delete(t.wrap(v))
+	if item == nil {
+		var zero T
+		return zero, false
+	}
+	return item.(value[T]).v, true
+}
+
+// Get returns the value in the tree which is equal to v, it returns false
+// if there is no such value.
+func (t *Tree[T]) Get(v T) (T, bool) {
+	item, ok := t.tree.Get(t.wrap(v))
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return item.(value[T]).v, true
+}
+
+// Ascend will call iterator once for each value in ascending order. It will
+// stop whenever the iterator returns false.
+func (t *Tree[T]) Ascend(iterator func(v T) bool) {
+	t.tree.AscendAll(func(i Item) bool {
+		return iterator(i.(value[T]).v)
+	})
+}
+
+// All returns an iterator over the values in ascending order, for the
+// range-over-func loops. Breaking out of the loop stops the traversal.
+func (t *Tree[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		t.Ascend(yield)
+	}
+}
+
+// Range returns an iterator over the values greater or equal than lo and
+// less than hi in ascending order, which means the range would be [lo, hi)
+// like AscendRange.
+func (t *Tree[T]) Range(lo, hi T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		t.tree.AscendRange(t.wrap(lo), t.wrap(hi), func(i Item) bool {
+			return yield(i.(value[T]).v)
+		})
+	}
+}
+
+// Fold calls fn once for each value of t in ascending order with the result
+// of the previous call, starting from acc, and returns the last result. It
+// is a function rather than a method since methods cannot have their own
+// type parameters.
+func Fold[T, A any](t *Tree[T], acc A, fn func(acc A, v T) A) A {
+	t.tree.walk(t.tree.root, func(i Item) bool {
+		acc = fn(acc, i.(value[T]).v)
+		return true
+	})
+	return acc
+}
+
+// Entry is a key and a value for a map-like Tree, which is typically
+// ordered by the keys.
+type Entry[K, V any] struct {
+	Key   K
+	Value V
+}
+
+// Entries returns the keys and the values of the entries of t in ascending
+// order, as two parallel slices.
+func Entries[K, V any](t *Tree[Entry[K, V]]) ([]K, []V) {
+	keys := make([]K, 0, t.Len())
+	values := make([]V, 0, t.Len())
+	t.tree.walk(t.tree.root, func(i Item) bool {
+		e := i.(value[Entry[K, V]]).v
+		keys = append(keys, e.Key)
+		values = append(values, e.Value)
+		return true
+	})
+	return keys, values
+}
@@ -0,0 +1,164 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestTreeInt(t *testing.T) {
+	tree := NewTree(func(a, b int) bool { return a < b })
+
+	for _, v := range rand.Perm(100) {
+		tree.Insert(v)
+	}
+	if tree.Len() != 100 {
+		t.Errorf("tree.Len() = %d, expect %d", tree.Len(), 100)
+	}
+
+	if v, ok := tree.Get(42); !ok || v != 42 {
+		t.Errorf("Get(42) = %v, %v, expect 42, true", v, ok)
+	}
+	if v, ok := tree.Delete(42); !ok || v != 42 {
+		t.Errorf("Delete(42) = %v, %v, expect 42, true", v, ok)
+	}
+	if _, ok := tree.Get(42); ok {
+		t.Errorf("42 is expect not exists")
+	}
+	if _, ok := tree.Delete(42); ok {
+		t.Errorf("42 is expect not exists")
+	}
+
+	var ret []int
+	tree.Ascend(func(v int) bool {
+		ret = append(ret, v)
+		return v < 44
+	})
+	expected := make([]int, 0, 44)
+	for i := 0; i <= 44; i++ {
+		if i != 42 {
+			expected = append(expected, i)
+		}
+	}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+}
+
+func TestTreeString(t *testing.T) {
+	// Order the strings by length first.
+	tree := NewTree(func(a, b string) bool {
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+
+	for _, s := range []string{"tree", "a", "red", "black", "go", "b"} {
+		tree.Insert(s)
+	}
+
+	var ret []string
+	tree.Ascend(func(s string) bool {
+		ret = append(ret, s)
+		return true
+	})
+	expected := []string{"a", "b", "go", "red", "tree", "black"}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	NewTree(func(a, b string) bool { return a < b }).Ascend(func(s string) bool {
+		t.Errorf("unexpected %v in empty tree", s)
+		return true
+	})
+}
+
+func TestTreeAllAndRange(t *testing.T) {
+	tree := NewTree(func(a, b string) bool { return a < b })
+	for v := range tree.All() {
+		t.Errorf("unexpected %v in empty tree", v)
+	}
+	for v := range tree.Range("a", "z") {
+		t.Errorf("unexpected %v in empty tree", v)
+	}
+
+	for _, s := range []string{"tree", "a", "red", "black", "go", "b"} {
+		tree.Insert(s)
+	}
+
+	var ret []string
+	for s := range tree.All() {
+		ret = append(ret, s)
+	}
+	expected := []string{"a", "b", "black", "go", "red", "tree"}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	ret = nil
+	for s := range tree.All() {
+		if s == "go" {
+			break
+		}
+		ret = append(ret, s)
+	}
+	if expected := []string{"a", "b", "black"}; !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	ret = nil
+	for s := range tree.Range("b", "red") {
+		ret = append(ret, s)
+	}
+	if expected := []string{"b", "black", "go"}; !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	ret = nil
+	for s := range tree.Range("b", "z") {
+		ret = append(ret, s)
+		if len(ret) == 2 {
+			break
+		}
+	}
+	if expected := []string{"b", "black"}; !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+}
+
+func TestFoldTree(t *testing.T) {
+	tree := NewTree(func(a, b string) bool { return a < b })
+	if n := Fold(tree, 0, func(n int, s string) int { return n + 1 }); n != 0 {
+		t.Errorf("Fold() = %d on an empty tree, expect 0", n)
+	}
+
+	for _, s := range []string{"c", "a", "d", "b"} {
+		tree.Insert(s)
+	}
+	if concat := Fold(tree, "", func(acc, s string) string { return acc + s }); concat != "abcd" {
+		t.Errorf("Fold() = %q, expect %q", concat, "abcd")
+	}
+	if n := Fold(tree, 0, func(n int, s string) int { return n + len(s) }); n != 4 {
+		t.Errorf("Fold() = %d, expect %d", n, 4)
+	}
+}
+
+func TestEntries(t *testing.T) {
+	tree := NewTree(func(a, b Entry[string, int]) bool { return a.Key < b.Key })
+	for _, s := range []string{"red", "black", "tree"} {
+		tree.Insert(Entry[string, int]{s, len(s)})
+	}
+
+	keys, values := Entries(tree)
+	if expected := []string{"black", "red", "tree"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v but got %v", expected, keys)
+	}
+	if expected := []int{5, 3, 4}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v but got %v", expected, values)
+	}
+}
@@ -4,6 +4,12 @@
 
 package rbtree
 
+import (
+	"context"
+	"fmt"
+	"iter"
+)
+
 // Iterator is the function of iteration entity which would be
 // used by those functions like `Ascend`, `Dscend`, etc.
 //
@@ -20,25 +26,127 @@ type Iterator func(i Item) bool
 
 // Ascend will call iterator once for each element greater or equal than pivot
 // in ascending order. It will stop whenever the iterator returns false.
+//
+// The nodes are visited one after another by following the parent pointers,
+// which needs neither recursion nor an explicit stack.
 func (t *Rbtree) Ascend(pivot Item, iterator Iterator) {
-	t.ascend(t.root, pivot, iterator)
+	for x := t.ceiling(pivot); x != t.NIL; x = t.successor(x) {
+		if !iterator(x.Item) {
+			return
+		}
+	}
 }
 
-func (t *Rbtree) ascend(x *Node, pivot Item, iterator Iterator) bool {
-	if x == t.NIL {
-		return true
+// AscendGreater will call iterator once for each element strictly greater
+// than pivot in ascending order. It will stop whenever the iterator returns
+// false.
+func (t *Rbtree) AscendGreater(pivot Item, iterator Iterator) {
+	for x := t.higher(pivot); x != t.NIL; x = t.successor(x) {
+		if !iterator(x.Item) {
+			return
+		}
 	}
+}
 
-	if !less(x.Item, pivot) {
-		if !t.ascend(x.Left, pivot, iterator) {
-			return false
+// AscendLess will call iterator once for each element strictly less than
+// pivot in ascending order. It will stop whenever the iterator returns
+// false.
+func (t *Rbtree) AscendLess(pivot Item, iterator Iterator) {
+	for x := t.min(t.root); x != t.NIL && t.less(x.Item, pivot); x = t.successor(x) {
+		if !iterator(x.Item) {
+			return
 		}
+	}
+}
+
+// AscendWhile will call visit once for each element greater or equal than
+// pivot in ascending order, as long as pred returns true for it. It will
+// stop at the first element for which pred returns false, which is not
+// visited, so that the stop condition is kept apart from the visit.
+func (t *Rbtree) AscendWhile(pivot Item, pred func(Item) bool, visit func(Item)) {
+	for x := t.ceiling(pivot); x != t.NIL && pred(x.Item); x = t.successor(x) {
+		visit(x.Item)
+	}
+}
+
+// AscendFromRank will call iterator once for each element from the k-th
+// smallest one on, counting from 0, in ascending order. The first one is
+// found by its rank rather than compared with a pivot. It will stop whenever
+// the iterator returns false.
+func (t *Rbtree) AscendFromRank(k int, iterator Iterator) {
+	if k < 0 {
+		k = 0
+	}
+
+	for x := t.selectNode(k); x != t.NIL; x = t.successor(x) {
 		if !iterator(x.Item) {
-			return false
+			return
 		}
 	}
+}
+
+// AscendSubtree will call iterator once for each element in the subtree of
+// the node which holds the item equal to root, in ascending order. It does
+// nothing if there is no such item. It will stop whenever the iterator
+// returns false.
+func (t *Rbtree) AscendSubtree(root Item, iterator Iterator) {
+	if root == nil {
+		return
+	}
+
+	t.walk(t.search(&Node{t.NIL, t.NIL, t.NIL, RED, 0, 0, root}), iterator)
+}
+
+// AscendAll will call iterator once for each element in ascending order,
+// without a pivot, which is awkward to build for some types of items. It
+// will stop whenever the iterator returns false.
+func (t *Rbtree) AscendAll(iterator Iterator) {
+	t.walk(t.root, iterator)
+}
+
+// contextCheckInterval is the number of items visited by AscendContext
+// between two checks of the context.
+const contextCheckInterval = 64
+
+// AscendContext is the same as Ascend except that it also stops whenever ctx
+// is done, which is checked once every few items, and returns ctx.Err() in
+// that case. It returns nil if the iteration has not been canceled.
+func (t *Rbtree) AscendContext(ctx context.Context, pivot Item, iterator Iterator) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var err error
+	n := 0
+	t.Ascend(pivot, func(i Item) bool {
+		n++
+		if n%contextCheckInterval == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+		return iterator(i)
+	})
+	return err
+}
 
-	return t.ascend(x.Right, pivot, iterator)
+// AscendSafe is the same as Ascend except that it recovers from a panic of
+// the iterator and returns it as an error, which wraps the panic value if it
+// is an error itself. The tree is never modified by the iteration, so it
+// stays usable afterwards. It returns nil if the iterator has not panicked.
+func (t *Rbtree) AscendSafe(pivot Item, iterator Iterator) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = fmt.Errorf("rbtree: iterator panicked: %w", e)
+			} else {
+				err = fmt.Errorf("rbtree: iterator panicked: %v", r)
+			}
+		}
+	}()
+
+	t.Ascend(pivot, iterator)
+	return nil
 }
 
 // Descend will call iterator once for each element less or equal than pivot
@@ -47,13 +155,45 @@ func (t *Rbtree) Descend(pivot Item, iterator Iterator) {
 	t.descend(t.root, pivot, iterator)
 }
 
+// DescendAll will call iterator once for each element in descending order,
+// without a pivot. It will stop whenever the iterator returns false.
+func (t *Rbtree) DescendAll(iterator Iterator) {
+	for x := t.max(t.root); x != t.NIL; x = t.predecessor(x) {
+		if !iterator(x.Item) {
+			return
+		}
+	}
+}
+
+// All returns an iterator over the items in ascending order, for the
+// range-over-func loops:
+//
+//	for item := range rbt.All() {
+//	        fmt.Println(item)
+//	}
+//
+// Breaking out of the loop stops the traversal.
+func (t *Rbtree) All() iter.Seq[Item] {
+	return func(yield func(Item) bool) {
+		t.AscendAll(yield)
+	}
+}
+
+// Backward returns an iterator over the items in descending order, the
+// counterpart of All.
+func (t *Rbtree) Backward() iter.Seq[Item] {
+	return func(yield func(Item) bool) {
+		t.DescendAll(yield)
+	}
+}
+
 func (t *Rbtree) descend(x *Node, pivot Item, iterator Iterator) bool {
 	if x == t.NIL {
 		return true
 	}
 
-	if !less(pivot, x.Item) {
-		if !t.descend(x.Right, pivot, iterator) {
+	if !t.less(pivot, x.Item) {
+		if !t.descend(x.right, pivot, iterator) {
 			return false
 		}
 		if !iterator(x.Item) {
@@ -61,35 +201,293 @@ func (t *Rbtree) descend(x *Node, pivot Item, iterator Iterator) bool {
 		}
 	}
 
-	return t.descend(x.Left, pivot, iterator)
+	return t.descend(x.left, pivot, iterator)
+}
+
+// AscendMorris will call iterator once for each element in ascending order
+// by the Morris traversal, which needs neither recursion nor the parent
+// pointers but threads the in-order predecessor of a node to it through its
+// empty right child while going down the left subtree, and unthreads it on
+// the way back. The tree is temporarily changed, so the iterator must not
+// access it, but it is fully restored before AscendMorris returns, even if
+// the iterator stops early or panics. It will stop whenever the iterator
+// returns false.
+func (t *Rbtree) AscendMorris(iterator Iterator) {
+	x := t.root
+	defer func() { t.unthread(x) }()
+
+	for x != t.NIL {
+		if x.left == t.NIL {
+			if !iterator(x.Item) {
+				return
+			}
+			x = x.right
+			continue
+		}
+
+		p := x.left
+		for p.right != t.NIL && p.right != x {
+			p = p.right
+		}
+		if p.right == t.NIL {
+			p.right = x
+			x = x.left
+			continue
+		}
+
+		p.right = t.NIL
+		if !iterator(x.Item) {
+			return
+		}
+		x = x.right
+	}
+}
+
+// unthread removes the threads left by AscendMorris stopped on x, which are
+// the ones to the ancestors of x whose left subtrees x is in. It climbs up by
+// the parent pointers, which AscendMorris leaves untouched.
+func (t *Rbtree) unthread(x *Node) {
+	if x == t.NIL {
+		return
+	}
+
+	for c, y := x, x.parent; y != t.NIL; c, y = y, y.parent {
+		if c != y.left {
+			continue
+		}
+		p := y.left
+		for p.right != y {
+			p = p.right
+		}
+		p.right = t.NIL
+	}
+}
+
+// walk calls iterator once for each item of the subtree rooted at x in
+// ascending order, it returns false once the iterator does.
+func (t *Rbtree) walk(x *Node, iterator Iterator) bool {
+	if x == t.NIL {
+		return true
+	}
+
+	return t.walk(x.left, iterator) && iterator(x.Item) && t.walk(x.right, iterator)
+}
+
+// ForEach calls fn once for each item in ascending order. Unlike Ascend it
+// always visits all the items.
+func (t *Rbtree) ForEach(fn func(Item)) {
+	t.walk(t.root, func(i Item) bool {
+		fn(i)
+		return true
+	})
+}
+
+// ForEachDescending calls fn once for each item in descending order. Unlike
+// Descend it always visits all the items.
+func (t *Rbtree) ForEachDescending(fn func(Item)) {
+	if t.root == t.NIL {
+		return
+	}
+
+	t.descend(t.root, t.max(t.root).Item, func(i Item) bool {
+		fn(i)
+		return true
+	})
+}
+
+// LevelOrder will call fn once for each element in breadth-first order,
+// i.e. level by level from the root, which is at level 0, and from left to
+// right within a level. It will stop whenever fn returns false.
+func (t *Rbtree) LevelOrder(fn func(item Item, level int) bool) {
+	if t.root == t.NIL {
+		return
+	}
+
+	queue := []*Node{t.root}
+	for level := 0; len(queue) > 0; level++ {
+		// The nodes of the next level are appended behind the current ones.
+		n := len(queue)
+		for _, x := range queue[:n] {
+			if !fn(x.Item, level) {
+				return
+			}
+			if x.left != t.NIL {
+				queue = append(queue, x.left)
+			}
+			if x.right != t.NIL {
+				queue = append(queue, x.right)
+			}
+		}
+		queue = queue[n:]
+	}
+}
+
+// AscendIndexed will call iterator once for each element in ascending order
+// together with its 0-based position. It will stop whenever the iterator
+// returns false.
+func (t *Rbtree) AscendIndexed(iterator func(index int, item Item) bool) {
+	index := 0
+	t.walk(t.root, func(i Item) bool {
+		index++
+		return iterator(index-1, i)
+	})
+}
+
+// AscendStride will call iterator once for every stride-th element in
+// ascending order, i.e. the ones at the positions 0, stride, 2*stride and so
+// on, a stride less than 1 counts as 1. It will stop whenever the iterator
+// returns false.
+func (t *Rbtree) AscendStride(stride int, iterator Iterator) {
+	if stride < 1 {
+		stride = 1
+	}
+
+	index := 0
+	t.walk(t.root, func(i Item) bool {
+		index++
+		return (index-1)%stride != 0 || iterator(i)
+	})
+}
+
+// Fold calls fn once for each item in ascending order with the result of the
+// previous call, starting from acc, and returns the last result. It returns
+// acc if the tree is empty.
+func (t *Rbtree) Fold(acc interface{}, fn func(acc interface{}, item Item) interface{}) interface{} {
+	t.walk(t.root, func(i Item) bool {
+		acc = fn(acc, i)
+		return true
+	})
+	return acc
+}
+
+// itemsBuffer is the capacity of the channels returned by Items.
+const itemsBuffer = 64
+
+// Items returns a channel which receives all the items in ascending order and
+// is closed after the last one. The tree must not be modified until then.
+//
+// The items are sent by a goroutine which blocks until they are received,
+// so the channel must be drained, otherwise the goroutine leaks together
+// with the tree. Use ItemsContext if the receiver may stop early.
+func (t *Rbtree) Items() <-chan Item {
+	return t.ItemsContext(context.Background())
+}
+
+// ItemsContext is the same as Items except that the goroutine also stops and
+// closes the channel once ctx is done, so that canceling ctx releases it
+// even if the channel is abandoned.
+func (t *Rbtree) ItemsContext(ctx context.Context) <-chan Item {
+	ch := make(chan Item, itemsBuffer)
+
+	go func() {
+		defer close(ch)
+		t.walk(t.root, func(i Item) bool {
+			select {
+			case ch <- i:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+
+	return ch
 }
 
 // AscendRange will call iterator once for elements greater or equal than @ge
 // and less than @lt, which means the range would be [ge, lt).
 // It will stop whenever the iterator returns false.
 func (t *Rbtree) AscendRange(ge, lt Item, iterator Iterator) {
-	t.ascendRange(t.root, ge, lt, iterator)
+	t.ascendRange(t.root, ge, lt, false, iterator)
 }
 
-func (t *Rbtree) ascendRange(x *Node, inf, sup Item, iterator Iterator) bool {
+// AscendInclusive will call iterator once for elements greater or equal than
+// @lo and less or equal than @hi, which means the range would be [lo, hi].
+// It will stop whenever the iterator returns false.
+func (t *Rbtree) AscendInclusive(lo, hi Item, iterator Iterator) {
+	t.ascendRange(t.root, lo, hi, true, iterator)
+}
+
+// ascendRange visits the items in [inf, sup), or in [inf, sup] if inclusive
+// is set.
+func (t *Rbtree) ascendRange(x *Node, inf, sup Item, inclusive bool, iterator Iterator) bool {
 	if x == t.NIL {
 		return true
 	}
 
-	if !less(x.Item, sup) {
-		return t.ascendRange(x.Left, inf, sup, iterator)
+	// Only one comparison is needed to tell whether x is above the range.
+	var above bool
+	if inclusive {
+		above = t.less(sup, x.Item)
+	} else {
+		above = !t.less(x.Item, sup)
 	}
-	if less(x.Item, inf) {
-		return t.ascendRange(x.Right, inf, sup, iterator)
+	if above {
+		return t.ascendRange(x.left, inf, sup, inclusive, iterator)
+	}
+	if t.less(x.Item, inf) {
+		return t.ascendRange(x.right, inf, sup, inclusive, iterator)
 	}
 
-	if !t.ascendRange(x.Left, inf, sup, iterator) {
+	if !t.ascendRange(x.left, inf, sup, inclusive, iterator) {
 		return false
 	}
 	if !iterator(x.Item) {
 		return false
 	}
-	return t.ascendRange(x.Right, inf, sup, iterator)
+	return t.ascendRange(x.right, inf, sup, inclusive, iterator)
+}
+
+// DescendRange will call iterator once for elements less or equal than @le
+// and greater than @gt, which means the range would be (gt, le].
+// It will stop whenever the iterator returns false.
+func (t *Rbtree) DescendRange(le, gt Item, iterator Iterator) {
+	t.descendRange(t.root, gt, le, iterator)
+}
+
+func (t *Rbtree) descendRange(x *Node, inf, sup Item, iterator Iterator) bool {
+	if x == t.NIL {
+		return true
+	}
+
+	if !t.less(inf, x.Item) {
+		return t.descendRange(x.right, inf, sup, iterator)
+	}
+	if t.less(sup, x.Item) {
+		return t.descendRange(x.left, inf, sup, iterator)
+	}
+
+	if !t.descendRange(x.right, inf, sup, iterator) {
+		return false
+	}
+	if !iterator(x.Item) {
+		return false
+	}
+	return t.descendRange(x.left, inf, sup, iterator)
+}
+
+// appendItems appends the items of the subtree rooted at x to dst in
+// ascending order.
+func (t *Rbtree) appendItems(dst []Item, x *Node) []Item {
+	if x == t.NIL {
+		return dst
+	}
+	dst = t.appendItems(dst, x.left)
+	dst = append(dst, x.Item)
+	return t.appendItems(dst, x.right)
+}
+
+// Collect returns all the items in ascending order, without the nodes
+// returned by SliceAscend.
+func (t *Rbtree) Collect() []Item {
+	return t.appendItems(make([]Item, 0, t.count), t.root)
+}
+
+// AppendAscend appends all the items in ascending order to dst and returns
+// the extended slice, the same way as append, so that a buffer can be
+// reused across the calls.
+func (t *Rbtree) AppendAscend(dst []Item) []Item {
+	return t.appendItems(dst, t.root)
 }
 
 // SliceAscend will recursively go through Nodes and return a slice of Nodes by ascending order.
@@ -105,10 +503,10 @@ func (t *Rbtree) dfsLeft(x *Node, count *int, result []*Node) {
 	if x == t.NIL {
 		return
 	}
-	t.dfsLeft(x.Left, count, result)
+	t.dfsLeft(x.left, count, result)
 	result[*count] = x
 	*count++
-	t.dfsLeft(x.Right, count, result)
+	t.dfsLeft(x.right, count, result)
 }
 
 // SliceDescend will recursively go through Nodes and return a slice of Nodes by descending order.
@@ -124,18 +522,17 @@ func (t *Rbtree) dfsRight(x *Node, count *int, result []*Node) {
 	if x == t.NIL {
 		return
 	}
-	result[*count] = x
-	t.dfsRight(x.Right, count, result)
+	t.dfsRight(x.right, count, result)
 	result[*count] = x
 	*count++
-	t.dfsRight(x.Left, count, result)
+	t.dfsRight(x.left, count, result)
 }
 
 // SliceAscendFirstN will recursively go through the first N Nodes and return a slice of Nodes by ascending order.
 func (t *Rbtree) SliceAscendFirstN(length int) []*Node {
 	n := uint(length)
-	if n > t.count {
-		n = t.count
+	if n > uint(t.count) {
+		n = uint(t.count)
 	}
 	result := make([]*Node, n)
 	count := 0
@@ -148,7 +545,7 @@ func (t *Rbtree) dfsLeftFirstN(x *Node, count *int, length int, result []*Node)
 	if x == t.NIL {
 		return
 	}
-	t.dfsLeftFirstN(x.Left, count, length, result)
+	t.dfsLeftFirstN(x.left, count, length, result)
 	if *count == length {
 		return
 	}
@@ -157,14 +554,14 @@ func (t *Rbtree) dfsLeftFirstN(x *Node, count *int, length int, result []*Node)
 	if *count == length {
 		return
 	}
-	t.dfsLeftFirstN(x.Right, count, length, result)
+	t.dfsLeftFirstN(x.right, count, length, result)
 }
 
-// SliceDescend will recursively go through the first N Nodes and return a slice of Nodes by descending order.
+// SliceDescendFirstN will recursively go through the first N Nodes and return a slice of Nodes by descending order.
 func (t *Rbtree) SliceDescendFirstN(length int) []*Node {
 	n := uint(length)
-	if n > t.count {
-		n = t.count
+	if n > uint(t.count) {
+		n = uint(t.count)
 	}
 	result := make([]*Node, n)
 	count := 0
@@ -177,8 +574,7 @@ func (t *Rbtree) dfsRightFirstN(x *Node, count *int, length int, result []*Node)
 	if x == t.NIL {
 		return
 	}
-	result[*count] = x
-	t.dfsRightFirstN(x.Right, count, length, result)
+	t.dfsRightFirstN(x.right, count, length, result)
 	if *count == length {
 		return
 	}
@@ -187,5 +583,5 @@ func (t *Rbtree) dfsRightFirstN(x *Node, count *int, length int, result []*Node)
 	if *count == length {
 		return
 	}
-	t.dfsRightFirstN(x.Left, count, length, result)
+	t.dfsRightFirstN(x.left, count, length, result)
 }
@@ -0,0 +1,120 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import "container/list"
+
+// lruList orders the nodes of a tree made by NewWithLRU by their last
+// accesses, which is a secondary ordering beside the one of the items. The
+// nodes stay the same while they are in the tree, even when a deletion
+// moves them around, so they can be kept apart from the tree itself.
+type lruList struct {
+	// order holds the entries from the least recently accessed one.
+	order *list.List
+	nodes map[*Node]*list.Element
+
+	// ticks counts the accesses, it is the access of the latest one.
+	ticks uint64
+}
+
+// lruEntry is the last access of the node.
+type lruEntry struct {
+	node   *Node
+	access uint64
+}
+
+func newLRUList() *lruList {
+	return &lruList{order: list.New(), nodes: make(map[*Node]*list.Element)}
+}
+
+// touch records an access of the node, which becomes the most recently
+// accessed one.
+func (l *lruList) touch(x *Node) {
+	l.ticks++
+	if e, ok := l.nodes[x]; ok {
+		e.Value.(*lruEntry).access = l.ticks
+		l.order.MoveToBack(e)
+		return
+	}
+	l.nodes[x] = l.order.PushBack(&lruEntry{x, l.ticks})
+}
+
+// remove forgets the node, which has been deleted from the tree.
+func (l *lruList) remove(x *Node) {
+	if e, ok := l.nodes[x]; ok {
+		l.order.Remove(e)
+		delete(l.nodes, x)
+	}
+}
+
+// reset forgets all the nodes, the counter keeps going so that the accesses
+// are still increasing.
+func (l *lruList) reset() {
+	l.order.Init()
+	clear(l.nodes)
+}
+
+// touch records an access of the node if the tree tracks them.
+func (t *Rbtree) touch(x *Node) {
+	if t.lru != nil {
+		t.lru.touch(x)
+	}
+}
+
+// adoptAccesses records the accesses of the nodes of other, which are moving
+// into t, after the ones of t and in the same order. The nodes are taken as
+// accessed in ascending order if other does not track the accesses.
+func (t *Rbtree) adoptAccesses(other *Rbtree) {
+	if t.lru == nil {
+		return
+	}
+
+	if other.lru == nil {
+		for _, x := range other.SliceAscend() {
+			t.lru.touch(x)
+		}
+		return
+	}
+
+	for e := other.lru.order.Front(); e != nil; e = e.Next() {
+		t.lru.touch(e.Value.(*lruEntry).node)
+	}
+	other.lru.reset()
+}
+
+// LastAccess returns the number of the last access of the item equal to key,
+// which grows with every access of any item in the tree, so that the item
+// with the smallest number is the least recently accessed one. It returns
+// false if there is no such item or the tree is not made by NewWithLRU.
+// Unlike Get, LastAccess does not count as an access.
+func (t *Rbtree) LastAccess(key Item) (uint64, bool) {
+	if key == nil || t.lru == nil {
+		return 0, false
+	}
+
+	x := t.find(key)
+	if x == t.NIL {
+		return 0, false
+	}
+	return t.lru.nodes[x].Value.(*lruEntry).access, true
+}
+
+// EvictLRU removes the n least recently accessed items from a tree made by
+// NewWithLRU and returns them from the least recently accessed one, or all
+// of them if there are fewer, and an empty slice if n is not positive or the
+// tree does not track the accesses.
+func (t *Rbtree) EvictLRU(n int) []Item {
+	if n <= 0 || t.lru == nil {
+		return []Item{}
+	}
+
+	result := make([]Item, 0, min(n, t.count))
+	for len(result) < n && t.count > 0 {
+		item := t.deleteNode(t.lru.order.Front().Value.(*lruEntry).node)
+		t.verify("EvictLRU", item)
+		result = append(result, item)
+	}
+	return result
+}
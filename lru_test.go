@@ -0,0 +1,223 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestEvictLRU(t *testing.T) {
+	rbt := NewWithLRU()
+	for i := 0; i < 10; i++ {
+		rbt.Insert(Int(i))
+	}
+	for _, key := range []Int{7, 2, 5} {
+		if _, ok := rbt.Get(key); !ok {
+			t.Fatalf("%d is expect exists", key)
+		}
+	}
+	// Neither a missing key nor Contains counts as an access.
+	rbt.Get(Int(100))
+	rbt.Contains(Int(0))
+
+	evicted := rbt.EvictLRU(7)
+	if expected := []Item{Int(0), Int(1), Int(3), Int(4), Int(6), Int(8), Int(9)}; !reflect.DeepEqual(evicted, expected) {
+		t.Errorf("expected %v but got %v", expected, evicted)
+	}
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if rbt.Len() != 3 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 3)
+	}
+
+	evicted = rbt.EvictLRU(10)
+	if expected := []Item{Int(7), Int(2), Int(5)}; !reflect.DeepEqual(evicted, expected) {
+		t.Errorf("expected %v but got %v", expected, evicted)
+	}
+	if rbt.Len() != 0 || len(rbt.EvictLRU(1)) != 0 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 0)
+	}
+
+	if ret := newIntTree(1, 2, 3).EvictLRU(2); ret == nil || len(ret) != 0 {
+		t.Errorf("EvictLRU() of a tree without LRU = %v, expect an empty slice", ret)
+	}
+	if ret := rbt.EvictLRU(0); ret == nil || len(ret) != 0 {
+		t.Errorf("EvictLRU(0) = %v, expect an empty slice", ret)
+	}
+}
+
+func TestEvictLRUAfterInit(t *testing.T) {
+	rbt := NewWithLRU()
+	for i := 0; i < 5; i++ {
+		rbt.Insert(Int(i))
+	}
+	rbt.Init()
+	rbt.Insert(Int(10))
+
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if evicted := rbt.EvictLRU(1); !reflect.DeepEqual(evicted, []Item{Int(10)}) {
+		t.Errorf("expected %v but got %v", []Item{Int(10)}, evicted)
+	}
+	if rbt.Len() != 0 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 0)
+	}
+}
+
+func TestLastAccess(t *testing.T) {
+	rbt := NewWithLRU()
+	rbt.InsertMany(Int(1), Int(2), Int(3))
+
+	last := func(key Int) uint64 {
+		access, ok := rbt.LastAccess(key)
+		if !ok {
+			t.Fatalf("LastAccess(%d) = false, expect true", key)
+		}
+		return access
+	}
+	if !(last(1) < last(2) && last(2) < last(3)) {
+		t.Errorf("the accesses %d, %d, %d are not increasing", last(1), last(2), last(3))
+	}
+
+	// LastAccess does not count, the insertions of equal items do.
+	before := last(1)
+	if last(1) != before {
+		t.Errorf("LastAccess() has counted as an access")
+	}
+	rbt.Get(Int(1))
+	if last(1) <= last(3) {
+		t.Errorf("Get() has not updated the access of 1")
+	}
+	rbt.Insert(Int(2))
+	rbt.GetOrInsert(Int(3))
+	if !(last(1) < last(2) && last(2) < last(3)) {
+		t.Errorf("the accesses %d, %d, %d are not increasing", last(1), last(2), last(3))
+	}
+
+	if _, ok := rbt.LastAccess(Int(4)); ok {
+		t.Errorf("LastAccess(4) = true, expect false")
+	}
+	if _, ok := newIntTree(1).LastAccess(Int(1)); ok {
+		t.Errorf("LastAccess() of a tree without LRU = true, expect false")
+	}
+}
+
+func TestEvictLRURandom(t *testing.T) {
+	rbt := NewWithLRU()
+	rbt.pool = &sync.Pool{New: func() interface{} { return new(Node) }}
+	rbt.SetDebug(true)
+
+	// model holds the items from the least recently accessed one.
+	var model []Item
+	remove := func(item Item) {
+		for i := range model {
+			if model[i] == item {
+				model = append(model[:i], model[i+1:]...)
+				return
+			}
+		}
+	}
+
+	for i := 0; i < 5000; i++ {
+		key := Int(rand.Intn(200))
+		switch rand.Intn(4) {
+		case 0, 1:
+			rbt.Insert(key)
+			remove(key)
+			model = append(model, key)
+		case 2:
+			if _, ok := rbt.Get(key); ok {
+				remove(key)
+				model = append(model, key)
+			}
+		case 3:
+			// Deleting the nodes with two children moves their successors.
+			rbt.Delete(key)
+			remove(key)
+		}
+	}
+
+	n := len(model) / 2
+	if evicted := rbt.EvictLRU(n); !reflect.DeepEqual(evicted, model[:n]) {
+		t.Errorf("expected %v but got %v", model[:n], evicted)
+	}
+	if evicted := rbt.EvictLRU(len(model)); !reflect.DeepEqual(evicted, model[n:]) {
+		t.Errorf("expected %v but got %v", model[n:], evicted)
+	}
+}
+
+func TestLRUTreesAnew(t *testing.T) {
+	rbt := NewWithLRU()
+	for _, v := range rand.Perm(100) {
+		rbt.Insert(Int(v))
+	}
+
+	// The counts of the accesses follow the trees made out of rbt.
+	check := func(name string, rbt *Rbtree) {
+		if err := rbt.CheckInvariants(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if rbt.lru == nil || len(rbt.lru.nodes) != rbt.Len() {
+			t.Errorf("%s does not track the accesses of its %d items", name, rbt.Len())
+		}
+	}
+	check("Clone", rbt.Clone())
+	check("Filter", rbt.Filter(func(i Item) bool { return i.(Int)%2 == 0 }))
+
+	rbt.BulkDelete([]Item{Int(1), Int(2), Int(3)})
+	rbt.RangeDelete(Int(10), Int(80))
+	check("BulkDelete and RangeDelete", rbt)
+	rbt.Rebuild()
+	check("Rebuild", rbt)
+
+	left, right := rbt.Split(Int(90))
+	check("Split", left)
+	check("Split", right)
+	right.Get(Int(90))
+	if err := left.Join(right); err != nil {
+		t.Fatal(err)
+	}
+	check("Join", left)
+	check("joined", right)
+	if evicted := left.EvictLRU(left.Len()); evicted[len(evicted)-1] != Int(90) {
+		t.Errorf("%v is evicted last, expect %v", evicted[len(evicted)-1], 90)
+	}
+
+	plain := newIntTree(200, 201)
+	lru := NewWithLRU()
+	lru.Insert(Int(1))
+	lru.Join(plain)
+	check("Join of a tree without LRU", lru)
+	lru.Clear()
+	check("Clear", lru)
+}
+
+func TestSafeRbtreeLRU(t *testing.T) {
+	s := NewSafe(NewWithLRU())
+	for i := 0; i < 100; i++ {
+		s.Insert(Int(i))
+	}
+
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				s.Get(Int(i))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := s.tree.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+}
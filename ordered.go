@@ -0,0 +1,108 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+// OrderedMap maps the keys to the values and keeps the keys in the order of
+// the less function given to NewOrderedMap, it is a Tree of the entries
+// ordered by their keys.
+type OrderedMap[K, V any] struct {
+	tree *Tree[Entry[K, V]]
+}
+
+// NewOrderedMap returns an empty OrderedMap which orders the keys by less.
+func NewOrderedMap[K, V any](less func(a, b K) bool) *OrderedMap[K, V] {
+	return &OrderedMap[K, V]{
+		tree: NewTree(func(a, b Entry[K, V]) bool { return less(a.Key, b.Key) }),
+	}
+}
+
+// Len returns the number of keys in the map.
+func (m *OrderedMap[K, V]) Len() int { return m.tree.Len() }
+
+// Set maps the key to the value, which replaces the previous value of an
+// equal key if there is one.
+func (m *OrderedMap[K, V]) Set(k K, v V) {
+	m.tree.Insert(Entry[K, V]{k, v})
+}
+
+// Get returns the value of the key, it returns false if there is no such
+// key.
+func (m *OrderedMap[K, V]) Get(k K) (V, bool) {
+	e, ok := m.tree.Get(Entry[K, V]{Key: k})
+	return e.Value, ok
+}
+
+// Delete deletes the key and its value, it returns false if there is no
+// such key.
+func (m *OrderedMap[K, V]) Delete(k K) bool {
+	_, ok := m.tree.Delete(Entry[K, V]{Key: k})
+	return ok
+}
+
+// Range will call fn once for each key and its value in ascending order of
+// the keys. It will stop whenever fn returns false.
+func (m *OrderedMap[K, V]) Range(fn func(k K, v V) bool) {
+	m.tree.Ascend(func(e Entry[K, V]) bool {
+		return fn(e.Key, e.Value)
+	})
+}
+
+// OrderedSet is a set of values kept in the order of the less function
+// given to NewOrderedSet.
+type OrderedSet[T any] struct {
+	tree *Tree[T]
+}
+
+// NewOrderedSet returns an empty OrderedSet which orders the values by less.
+func NewOrderedSet[T any](less func(a, b T) bool) *OrderedSet[T] {
+	return &OrderedSet[T]{tree: NewTree(less)}
+}
+
+// wrap returns a set of the same order as s holding the items of rbt.
+func (s *OrderedSet[T]) wrap(rbt *Rbtree) *OrderedSet[T] {
+	return &OrderedSet[T]{tree: &Tree[T]{tree: rbt}}
+}
+
+// Len returns the number of values in the set.
+func (s *OrderedSet[T]) Len() int { return s.tree.Len() }
+
+// Add adds the value to the set, it returns false if there is already an
+// equal value, which is replaced by v.
+func (s *OrderedSet[T]) Add(v T) bool { return s.tree.Insert(v) }
+
+// Remove removes the value from the set, it returns false if there is no
+// such value.
+func (s *OrderedSet[T]) Remove(v T) bool {
+	_, ok := s.tree.Delete(v)
+	return ok
+}
+
+// Contains returns whether the value is in the set.
+func (s *OrderedSet[T]) Contains(v T) bool {
+	_, ok := s.tree.Get(v)
+	return ok
+}
+
+// Range will call fn once for each value in ascending order. It will stop
+// whenever fn returns false.
+func (s *OrderedSet[T]) Range(fn func(v T) bool) { s.tree.Ascend(fn) }
+
+// Union returns a new set of the values which are in either s or other,
+// the value of s is kept if both have an equal one.
+func (s *OrderedSet[T]) Union(other *OrderedSet[T]) *OrderedSet[T] {
+	return s.wrap(s.tree.tree.Union(other.tree.tree))
+}
+
+// Intersect returns a new set of the values which are in both s and other,
+// the ones of s are kept.
+func (s *OrderedSet[T]) Intersect(other *OrderedSet[T]) *OrderedSet[T] {
+	return s.wrap(s.tree.tree.Intersect(other.tree.tree))
+}
+
+// Difference returns a new set of the values which are in s but not in
+// other.
+func (s *OrderedSet[T]) Difference(other *OrderedSet[T]) *OrderedSet[T] {
+	return s.wrap(s.tree.tree.Difference(other.tree.tree))
+}
@@ -0,0 +1,199 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestOrderedMap(t *testing.T) {
+	m := NewOrderedMap[string, int](func(a, b string) bool { return a < b })
+	m.Range(func(k string, v int) bool {
+		t.Errorf("unexpected %v: %v in empty map", k, v)
+		return true
+	})
+
+	for _, s := range []string{"tree", "a", "red", "black", "go", "b"} {
+		m.Set(s, len(s))
+	}
+	if m.Len() != 6 {
+		t.Errorf("map.Len() = %d, expect %d", m.Len(), 6)
+	}
+	if v, ok := m.Get("black"); !ok || v != 5 {
+		t.Errorf("Get(black) = %v, %v, expect 5, true", v, ok)
+	}
+	if v, ok := m.Get("white"); ok || v != 0 {
+		t.Errorf("Get(white) = %v, %v, expect 0, false", v, ok)
+	}
+
+	m.Set("go", 42)
+	if v, ok := m.Get("go"); !ok || v != 42 {
+		t.Errorf("Get(go) = %v, %v, expect 42, true", v, ok)
+	}
+	if m.Len() != 6 {
+		t.Errorf("map.Len() = %d after overwriting, expect %d", m.Len(), 6)
+	}
+
+	if !m.Delete("red") {
+		t.Errorf("Delete(red) = false, expect true")
+	}
+	if m.Delete("red") {
+		t.Errorf("Delete(red) = true after deleting it, expect false")
+	}
+	if _, ok := m.Get("red"); ok {
+		t.Errorf("red is expect not exists")
+	}
+
+	var keys []string
+	var values []int
+	m.Range(func(k string, v int) bool {
+		keys = append(keys, k)
+		values = append(values, v)
+		return true
+	})
+	if expected := []string{"a", "b", "black", "go", "tree"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v but got %v", expected, keys)
+	}
+	if expected := []int{1, 1, 5, 42, 4}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v but got %v", expected, values)
+	}
+
+	keys = nil
+	m.Range(func(k string, v int) bool {
+		keys = append(keys, k)
+		return len(keys) < 2
+	})
+	if expected := []string{"a", "b"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v but got %v", expected, keys)
+	}
+}
+
+func TestOrderedMapRandom(t *testing.T) {
+	m := NewOrderedMap[int, int](func(a, b int) bool { return a < b })
+	model := map[int]int{}
+	for i := 0; i < 2000; i++ {
+		k := rand.Intn(100)
+		if rand.Intn(3) == 0 {
+			if _, ok := model[k]; m.Delete(k) != ok {
+				t.Fatalf("Delete(%d) = %v, expect %v", k, !ok, ok)
+			}
+			delete(model, k)
+		} else {
+			m.Set(k, i)
+			model[k] = i
+		}
+	}
+
+	if m.Len() != len(model) {
+		t.Errorf("map.Len() = %d, expect %d", m.Len(), len(model))
+	}
+	prev := -1
+	m.Range(func(k, v int) bool {
+		if k <= prev {
+			t.Errorf("%d is visited after %d", k, prev)
+		}
+		if model[k] != v {
+			t.Errorf("%d maps to %d, expect %d", k, v, model[k])
+		}
+		prev = k
+		return true
+	})
+}
+
+// setValues returns the values of the set in ascending order.
+func setValues(s *OrderedSet[int]) []int {
+	values := []int{}
+	s.Range(func(v int) bool {
+		values = append(values, v)
+		return true
+	})
+	return values
+}
+
+func newIntSet(values ...int) *OrderedSet[int] {
+	s := NewOrderedSet(func(a, b int) bool { return a < b })
+	for _, v := range values {
+		s.Add(v)
+	}
+	return s
+}
+
+func TestOrderedSet(t *testing.T) {
+	s := newIntSet()
+	if s.Len() != 0 || s.Contains(1) || s.Remove(1) {
+		t.Errorf("empty set has %v", setValues(s))
+	}
+
+	for _, v := range rand.Perm(10) {
+		if !s.Add(v * 2) {
+			t.Errorf("Add(%d) = false, expect true", v*2)
+		}
+	}
+	if s.Add(4) {
+		t.Errorf("Add(4) = true for an existing value, expect false")
+	}
+	if !s.Remove(6) || s.Remove(6) {
+		t.Errorf("Remove(6) twice does not return true then false")
+	}
+	if !s.Contains(8) || s.Contains(6) || s.Contains(7) {
+		t.Errorf("Contains() does not match %v", setValues(s))
+	}
+	if s.Len() != 9 {
+		t.Errorf("set.Len() = %d, expect %d", s.Len(), 9)
+	}
+	if expected := []int{0, 2, 4, 8, 10, 12, 14, 16, 18}; !reflect.DeepEqual(setValues(s), expected) {
+		t.Errorf("expected %v but got %v", expected, setValues(s))
+	}
+
+	var ret []int
+	s.Range(func(v int) bool {
+		ret = append(ret, v)
+		return v < 4
+	})
+	if expected := []int{0, 2, 4}; !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+}
+
+func TestOrderedSetAlgebra(t *testing.T) {
+	a := newIntSet(1, 2, 3, 4, 5, 6)
+	b := newIntSet(4, 5, 6, 7, 8)
+	empty := newIntSet()
+
+	tests := []struct {
+		name     string
+		set      *OrderedSet[int]
+		expected []int
+	}{
+		{"a | b", a.Union(b), []int{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"a & b", a.Intersect(b), []int{4, 5, 6}},
+		{"a - b", a.Difference(b), []int{1, 2, 3}},
+		{"b - a", b.Difference(a), []int{7, 8}},
+		{"a | empty", a.Union(empty), []int{1, 2, 3, 4, 5, 6}},
+		{"a & empty", a.Intersect(empty), []int{}},
+		{"empty - a", empty.Difference(a), []int{}},
+	}
+	for _, test := range tests {
+		if values := setValues(test.set); !reflect.DeepEqual(values, test.expected) {
+			t.Errorf("%s = %v, expect %v", test.name, values, test.expected)
+		}
+		if test.set.Len() != len(test.expected) {
+			t.Errorf("%s has Len() = %d, expect %d", test.name, test.set.Len(), len(test.expected))
+		}
+	}
+
+	// The operands are left untouched and the results are sets of their own.
+	u := a.Union(b)
+	u.Add(42)
+	u.Remove(1)
+	if !a.Contains(1) || a.Contains(42) || a.Len() != 6 || b.Len() != 5 {
+		t.Errorf("operands have changed to %v and %v", setValues(a), setValues(b))
+	}
+	if !u.Contains(42) || u.Contains(1) {
+		t.Errorf("union is %v after updates", setValues(u))
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+// Persistent is an immutable Red-Black tree, Insert and Delete return a new
+// tree and leave the original one untouched, so that every version stays a
+// valid snapshot.
+//
+// The nodes of Rbtree cannot be shared between versions because of their
+// parent pointers, hence Persistent is a left-leaning Red-Black tree whose
+// nodes only point to their children. The nodes on the path from the root to
+// the changed one are copied and all the other subtrees are shared, which
+// costs O(log n) new nodes per change.
+//
+// The zero value is an empty tree ready to use.
+type Persistent struct {
+	root  *pnode
+	count int
+}
+
+// pnode is a node of Persistent, it must not be modified once it is
+// reachable from a Persistent.
+type pnode struct {
+	left, right *pnode
+	color       uint
+	item        Item
+}
+
+// NewPersistent returns an empty persistent tree.
+func NewPersistent() *Persistent { return new(Persistent) }
+
+// Len returns number of items in the tree.
+func (p *Persistent) Len() int { return p.count }
+
+// Get returns the item in the tree which is equal to the specified key, it
+// returns false if there is no such item.
+func (p *Persistent) Get(key Item) (Item, bool) {
+	if key == nil {
+		return nil, false
+	}
+
+	for h := p.root; h != nil; {
+		if key.Less(h.item) {
+			h = h.left
+		} else if h.item.Less(key) {
+			h = h.right
+		} else {
+			return h.item, true
+		}
+	}
+	return nil, false
+}
+
+// Insert returns a new tree which also holds the item, an equal item is
+// replaced the same way as by Rbtree.Insert.
+func (p *Persistent) Insert(item Item) *Persistent {
+	if item == nil {
+		return p
+	}
+
+	count := p.count
+	if _, ok := p.Get(item); !ok {
+		count++
+	}
+
+	root := pinsert(p.root, item)
+	root.color = BLACK
+	return &Persistent{root: root, count: count}
+}
+
+// Delete returns a new tree without the item equal to the specified one, or
+// the tree itself if there is no such item.
+func (p *Persistent) Delete(item Item) *Persistent {
+	if _, ok := p.Get(item); !ok {
+		return p
+	}
+
+	root := p.root.clone()
+	if !isRed(root.left) && !isRed(root.right) {
+		root.color = RED
+	}
+	root = pdelete(root, item)
+	if root != nil {
+		root.color = BLACK
+	}
+	return &Persistent{root: root, count: p.count - 1}
+}
+
+// Ascend will call iterator once for each item greater or equal than pivot
+// in ascending order. It will stop whenever the iterator returns false.
+func (p *Persistent) Ascend(pivot Item, iterator Iterator) {
+	pascend(p.root, pivot, iterator)
+}
+
+func pascend(h *pnode, pivot Item, iterator Iterator) bool {
+	if h == nil {
+		return true
+	}
+
+	if !h.item.Less(pivot) {
+		if !pascend(h.left, pivot, iterator) {
+			return false
+		}
+		if !iterator(h.item) {
+			return false
+		}
+	}
+
+	return pascend(h.right, pivot, iterator)
+}
+
+// clone returns a copy of h which can be modified.
+func (h *pnode) clone() *pnode {
+	c := *h
+	return &c
+}
+
+func isRed(h *pnode) bool { return h != nil && h.color == RED }
+
+// The helpers below take a node which has already been copied, and copy the
+// children they modify.
+
+func protateLeft(h *pnode) *pnode {
+	x := h.right.clone()
+	h.right = x.left
+	x.left = h
+	x.color = h.color
+	h.color = RED
+	return x
+}
+
+func protateRight(h *pnode) *pnode {
+	x := h.left.clone()
+	h.left = x.right
+	x.right = h
+	x.color = h.color
+	h.color = RED
+	return x
+}
+
+func pflip(h *pnode) {
+	h.left, h.right = h.left.clone(), h.right.clone()
+	h.color ^= 1
+	h.left.color ^= 1
+	h.right.color ^= 1
+}
+
+// pfixUp restores the left-leaning properties at h on the way up.
+func pfixUp(h *pnode) *pnode {
+	if isRed(h.right) && !isRed(h.left) {
+		h = protateLeft(h)
+	}
+	if isRed(h.left) && isRed(h.left.left) {
+		h = protateRight(h)
+	}
+	if isRed(h.left) && isRed(h.right) {
+		pflip(h)
+	}
+	return h
+}
+
+func pinsert(h *pnode, item Item) *pnode {
+	if h == nil {
+		return &pnode{color: RED, item: item}
+	}
+
+	h = h.clone()
+	if item.Less(h.item) {
+		h.left = pinsert(h.left, item)
+	} else if h.item.Less(item) {
+		h.right = pinsert(h.right, item)
+	} else {
+		h.item = item
+	}
+	return pfixUp(h)
+}
+
+// pmoveRedLeft makes h.left or one of its children red, assuming that h is
+// red and both h.left and h.left.left are black.
+func pmoveRedLeft(h *pnode) *pnode {
+	pflip(h)
+	if isRed(h.right.left) {
+		h.right = protateRight(h.right)
+		h = protateLeft(h)
+		pflip(h)
+	}
+	return h
+}
+
+// pmoveRedRight makes h.right or one of its children red, assuming that h is
+// red and both h.right and h.right.left are black.
+func pmoveRedRight(h *pnode) *pnode {
+	pflip(h)
+	if isRed(h.left.left) {
+		h = protateRight(h)
+		pflip(h)
+	}
+	return h
+}
+
+// pdeleteMin removes the minimum node of the subtree rooted at the copied
+// node h.
+func pdeleteMin(h *pnode) *pnode {
+	if h.left == nil {
+		return nil
+	}
+
+	if !isRed(h.left) && !isRed(h.left.left) {
+		h = pmoveRedLeft(h)
+	}
+	h.left = pdeleteMin(h.left.clone())
+	return pfixUp(h)
+}
+
+// pdelete removes the node equal to item, which must be in the subtree
+// rooted at the copied node h.
+func pdelete(h *pnode, item Item) *pnode {
+	if item.Less(h.item) {
+		if !isRed(h.left) && !isRed(h.left.left) {
+			h = pmoveRedLeft(h)
+		}
+		h.left = pdelete(h.left.clone(), item)
+		return pfixUp(h)
+	}
+
+	if isRed(h.left) {
+		h = protateRight(h)
+	}
+	if !h.item.Less(item) && h.right == nil {
+		return nil
+	}
+	if !isRed(h.right) && !isRed(h.right.left) {
+		h = pmoveRedRight(h)
+	}
+	if !h.item.Less(item) {
+		// Replace h by its successor, which the right subtree gives up.
+		m := h.right
+		for m.left != nil {
+			m = m.left
+		}
+		h.item = m.item
+		h.right = pdeleteMin(h.right.clone())
+	} else {
+		h.right = pdelete(h.right.clone(), item)
+	}
+	return pfixUp(h)
+}
@@ -0,0 +1,136 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// checkPersistent verifies the left-leaning Red-Black properties and the
+// count of the tree.
+func checkPersistent(t *testing.T, p *Persistent) {
+	t.Helper()
+
+	if isRed(p.root) {
+		t.Fatalf("root %v is not black", p.root.item)
+	}
+
+	var check func(h *pnode) (int, int)
+	check = func(h *pnode) (int, int) {
+		if h == nil {
+			return 0, 1
+		}
+		if isRed(h.right) {
+			t.Fatalf("node %v has a red right child", h.item)
+		}
+		if isRed(h) && isRed(h.left) {
+			t.Fatalf("red node %v has a red child", h.item)
+		}
+		if h.left != nil && !h.left.item.Less(h.item) {
+			t.Fatalf("node %v is not less than its parent %v", h.left.item, h.item)
+		}
+		if h.right != nil && !h.item.Less(h.right.item) {
+			t.Fatalf("node %v is not greater than its parent %v", h.right.item, h.item)
+		}
+
+		ln, lbh := check(h.left)
+		rn, rbh := check(h.right)
+		if lbh != rbh {
+			t.Fatalf("node %v has black heights %d and %d", h.item, lbh, rbh)
+		}
+		if h.color == BLACK {
+			lbh++
+		}
+		return ln + rn + 1, lbh
+	}
+
+	if n, _ := check(p.root); n != p.Len() {
+		t.Fatalf("tree.Len() = %d but there are %d nodes", p.Len(), n)
+	}
+}
+
+func persistentItems(p *Persistent) []Item {
+	var ret []Item
+	p.Ascend(Int(-1<<31), func(i Item) bool {
+		ret = append(ret, i)
+		return true
+	})
+	return ret
+}
+
+func TestPersistent(t *testing.T) {
+	p := NewPersistent()
+	values := map[int]bool{}
+
+	for round := 0; round < 2000; round++ {
+		v := rand.Intn(500)
+		if rand.Intn(3) == 0 {
+			p = p.Delete(Int(v))
+			delete(values, v)
+		} else {
+			p = p.Insert(Int(v))
+			values[v] = true
+		}
+		checkPersistent(t, p)
+	}
+
+	if p.Len() != len(values) {
+		t.Errorf("tree.Len() = %d, expect %d", p.Len(), len(values))
+	}
+	for v := 0; v < 500; v++ {
+		if _, ok := p.Get(Int(v)); ok != values[v] {
+			t.Errorf("tree.Get(%d) = %v, expect %v", v, ok, values[v])
+		}
+	}
+
+	for v := range values {
+		p = p.Delete(Int(v))
+		checkPersistent(t, p)
+	}
+	if p.Len() != 0 || p.root != nil {
+		t.Errorf("tree.Len() = %d, expect an empty tree", p.Len())
+	}
+}
+
+func TestPersistentSnapshot(t *testing.T) {
+	snapshot := NewPersistent()
+	for _, v := range rand.Perm(100) {
+		snapshot = snapshot.Insert(Int(v))
+	}
+	expected := persistentItems(snapshot)
+
+	p := snapshot
+	for v := 0; v < 100; v += 2 {
+		p = p.Delete(Int(v))
+	}
+	for v := 100; v < 150; v++ {
+		p = p.Insert(Int(v))
+	}
+	p = p.Insert(Int(1))
+	checkPersistent(t, p)
+
+	if p.Len() != 100 {
+		t.Errorf("tree.Len() = %d, expect %d", p.Len(), 100)
+	}
+	if _, ok := p.Get(Int(0)); ok {
+		t.Errorf("0 is expect not exists")
+	}
+
+	// The snapshot is left untouched.
+	checkPersistent(t, snapshot)
+	if snapshot.Len() != 100 {
+		t.Errorf("snapshot.Len() = %d, expect %d", snapshot.Len(), 100)
+	}
+	if ret := persistentItems(snapshot); !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	// Deleting an absent item returns the same tree.
+	if q := snapshot.Delete(Int(1000)); q != snapshot {
+		t.Errorf("Delete(1000) returned a new tree")
+	}
+}
@@ -5,6 +5,12 @@
 // Package rbtree implements operations on Red-Black tree.
 package rbtree
 
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
 //
 // Red-Black tree properties:  http://en.wikipedia.org/wiki/Rbtree
 //
@@ -18,15 +24,57 @@ package rbtree
 
 // Node of the rbtree has a pointer of the node of parent, left, right, also has own color and Item which client uses
 type Node struct {
-	Left   *Node
-	Right  *Node
-	Parent *Node
-	Color  uint
+	left   *Node
+	right  *Node
+	parent *Node
+	color  uint
+
+	// size is the number of nodes in the subtree rooted at this node,
+	// which is maintained for the order statistics. NIL has size 0.
+	size int
+
+	// sum is the total weight of the subtree rooted at this node, which is
+	// maintained like size if the tree has a Weigher. NIL has sum 0.
+	sum float64
 
 	// for use by client.
 	Item
 }
 
+// Value returns the item carried by the node.
+func (n *Node) Value() Item { return n.Item }
+
+// Color returns the color of the node, which is either "red" or "black".
+func (n *Node) Color() string {
+	if n.color == RED {
+		return "red"
+	}
+	return "black"
+}
+
+// IsNil returns whether n is nil or the NIL sentinel of a tree, which is
+// the leaf of all the nodes. The sentinels have no children, neither does a
+// node which has been deleted, so it counts as nil as well. The exception is
+// a tree made by NewWithPool, where the node of an item deleted other than
+// by DeleteNode may be reused for another item and then is no longer nil.
+func (n *Node) IsNil() bool { return n == nil || n.left == nil }
+
+// Left returns the left child of the node, or nil if there is none.
+func (n *Node) Left() *Node {
+	if n.IsNil() || n.left.IsNil() {
+		return nil
+	}
+	return n.left
+}
+
+// Right returns the right child of the node, or nil if there is none.
+func (n *Node) Right() *Node {
+	if n.IsNil() || n.right.IsNil() {
+		return nil
+	}
+	return n.right
+}
+
 const (
 	// RED represents the color of the node is red
 	RED = 0
@@ -39,33 +87,324 @@ type Item interface {
 	Less(than Item) bool
 }
 
+// Comparator returns a negative number if a is less than b, a positive number
+// if a is greater than b and zero if they are equal.
+type Comparator func(a, b Item) int
+
+// Weigher returns the weight of an item for the weighted trees made by
+// NewWeighted.
+type Weigher func(Item) float64
+
 // Rbtree represents a Red-Black tree.
+//
+// All the leaves and the parent of the root are the sentinel NIL. The
+// parent of NIL itself is meaningless except during a deletion, which
+// uses it to climb up from a removed leaf.
 type Rbtree struct {
 	NIL   *Node
 	root  *Node
-	count uint
+	count int
+
+	// cmp takes the place of Item.Less if it is not nil.
+	cmp Comparator
+
+	// decodeJSON builds the items for UnmarshalJSON.
+	decodeJSON func(data []byte) (Item, error)
+
+	// codec encodes and decodes the items for Save and Load.
+	codec Codec
+
+	// pool recycles the deleted nodes if it is not nil.
+	pool *sync.Pool
+
+	// dup allows the tree to hold several equal items, which are kept in
+	// the order of their insertion.
+	dup bool
+
+	// equal tells apart the items which are at the same position, it is
+	// used by Get, Contains and Replace if it is not nil.
+	equal func(a, b Item) bool
+
+	// debug checks the invariants after every Insert and Delete.
+	debug bool
+
+	// bound is the maximum number of items kept by Insert, 0 means there is
+	// no bound.
+	bound int
+
+	// compares counts the comparisons if counting is set, atomically since
+	// the readers may share the tree.
+	counting bool
+	compares atomic.Int64
+
+	// weigh gives the weights of the items summed up in the nodes if it is
+	// not nil.
+	weigh Weigher
+
+	// lru orders the nodes by their last accesses if it is not nil.
+	lru *lruList
 }
 
-func less(x, y Item) bool {
+func (t *Rbtree) less(x, y Item) bool {
+	if t.counting {
+		t.compares.Add(1)
+	}
+	if t.cmp != nil {
+		return t.cmp(x, y) < 0
+	}
 	return x.Less(y)
 }
 
 // New returns an initialized Red-Black tree
 func New() *Rbtree { return new(Rbtree).Init() }
 
-// Init returns the initial of rbtree
+// NewWithComparator returns an initialized Red-Black tree which orders the
+// items by cmp, the Less method of the items will never be called.
+func NewWithComparator(cmp Comparator) *Rbtree {
+	t := New()
+	t.cmp = cmp
+	return t
+}
+
+// Reverse returns a comparator which orders the items the other way round
+// from cmp.
+func Reverse(cmp Comparator) Comparator {
+	return func(a, b Item) int { return cmp(b, a) }
+}
+
+// NewReverse returns an initialized Red-Black tree which orders the items
+// by their Less method the other way round, so that Ascend goes from the
+// greatest item to the least one.
+func NewReverse() *Rbtree {
+	return NewWithComparator(func(a, b Item) int {
+		if b.Less(a) {
+			return -1
+		}
+		if a.Less(b) {
+			return 1
+		}
+		return 0
+	})
+}
+
+// NewWithPool returns an initialized Red-Black tree which recycles the nodes
+// of the deleted items for the inserted ones. The nodes returned by the
+// tree, e.g. by SliceAscend, must not be kept after their items have been
+// deleted since they may carry other items later, except for the ones
+// deleted by DeleteNode, which are never recycled.
+func NewWithPool() *Rbtree {
+	t := New()
+	t.pool = &sync.Pool{New: func() interface{} { return new(Node) }}
+	return t
+}
+
+// NewWithDuplicates returns an initialized Red-Black tree which holds
+// several equal items if allow is true. Insert then adds an equal item after
+// the stored ones, and Get, Delete, etc. act on the first of them. Otherwise
+// it is the same as New, i.e. Insert replaces the equal item.
+func NewWithDuplicates(allow bool) *Rbtree {
+	t := New()
+	t.dup = allow
+	return t
+}
+
+// NewBounded returns an initialized Red-Black tree which keeps the k
+// greatest items inserted by Insert. Once there are k items, Insert evicts
+// the minimum one for a greater item and drops any other one. The other
+// ways to add items, e.g. InsertOrGet or BulkInsertSorted, do not enforce
+// the bound. A k which is not positive means no bound, the same as New.
+func NewBounded(k int) *Rbtree {
+	t := New()
+	if k > 0 {
+		t.bound = k
+	}
+	return t
+}
+
+// NewWeighted returns an initialized Red-Black tree which weighs each item
+// by w and maintains the total weight of every subtree along with its size,
+// so that PrefixWeight takes O(log n). The weight of an item must not change
+// while it is in the tree.
+func NewWeighted(w Weigher) *Rbtree {
+	t := New()
+	t.weigh = w
+	return t
+}
+
+// NewWithLRU returns an initialized Red-Black tree which also orders the
+// nodes by their last accesses, so that EvictLRU removes the least recently
+// accessed items. An item is accessed when it is inserted and whenever it is
+// found by Get, or by an insertion of an equal item, e.g. GetOrInsert. The
+// operations which build the nodes anew from the items, e.g. Clone, Rebuild
+// or BulkInsertSorted, take all of them as accessed once in no particular
+// order.
+func NewWithLRU() *Rbtree {
+	t := New()
+	t.lru = newLRUList()
+	return t
+}
+
+// SetEqual sets the function which tells whether two items which are at
+// the same position, i.e. neither of them is less than the other, are equal
+// for Get, Contains and Replace. Those then only match the items equal to
+// the specified one, which matters mostly for a tree allowing duplicates
+// since only such a tree can hold several items at the same position. A nil
+// equal restores the default, which treats all of them as equal.
+func (t *Rbtree) SetEqual(equal func(a, b Item) bool) {
+	t.equal = equal
+}
+
+// Init initializes or clears the tree t in place and returns it, the
+// settings of t, e.g. its comparator or its pool, are kept. The nodes of a
+// tree made by NewWithPool go back to the pool, as Clear does.
 func (t *Rbtree) Init() *Rbtree {
-	node := &Node{nil, nil, nil, BLACK, nil}
-	return &Rbtree{
-		NIL:   node,
-		root:  node,
-		count: 0,
+	if t.pool != nil && t.NIL != nil {
+		t.freeTree(t.root)
+	}
+	return t.reset()
+}
+
+// reset empties the tree with a new sentinel and returns it, the nodes are
+// left alone since they may have been moved into another tree.
+func (t *Rbtree) reset() *Rbtree {
+	node := &Node{nil, nil, nil, BLACK, 0, 0, nil}
+	t.NIL = node
+	t.root = node
+	t.count = 0
+	if t.lru != nil {
+		t.lru.reset()
+	}
+	return t
+}
+
+// emptyClone returns an empty tree which has the same settings as t.
+func (t *Rbtree) emptyClone() *Rbtree {
+	c := New()
+	c.cmp = t.cmp
+	c.decodeJSON = t.decodeJSON
+	c.codec = t.codec
+	c.pool = t.pool
+	c.dup = t.dup
+	c.equal = t.equal
+	c.debug = t.debug
+	c.bound = t.bound
+	c.counting = t.counting
+	c.weigh = t.weigh
+	if t.lru != nil {
+		c.lru = newLRUList()
+	}
+	return c
+}
+
+// newNode returns a RED leaf carrying the item, it is taken from the pool if
+// there is one.
+func (t *Rbtree) newNode(item Item) *Node {
+	var x *Node
+	if t.pool == nil {
+		x = &Node{t.NIL, t.NIL, t.NIL, RED, 1, t.weight(item), item}
+	} else {
+		x = t.pool.Get().(*Node)
+		*x = Node{t.NIL, t.NIL, t.NIL, RED, 1, t.weight(item), item}
+	}
+
+	if t.lru != nil {
+		t.lru.touch(x)
+	}
+	return x
+}
+
+// weight returns the weight of the item, which is 0 if the tree is not
+// weighted.
+func (t *Rbtree) weight(item Item) float64 {
+	if t.weigh == nil {
+		return 0
+	}
+	return t.weigh(item)
+}
+
+// reweigh recounts the sums of the weights of x and all its ancestors from
+// their children, after the subtree rooted at x has changed.
+func (t *Rbtree) reweigh(x *Node) {
+	if t.weigh == nil {
+		return
+	}
+
+	for ; x != t.NIL; x = x.parent {
+		x.sum = x.left.sum + x.right.sum + t.weigh(x.Item)
+	}
+}
+
+// freeNode puts the node, which has been removed from the tree, back to the
+// pool if there is one.
+func (t *Rbtree) freeNode(x *Node) {
+	if t.pool == nil {
+		return
 	}
+
+	*x = Node{}
+	t.pool.Put(x)
+}
+
+// freeTree puts all the nodes of the subtree rooted at x back to the pool.
+func (t *Rbtree) freeTree(x *Node) {
+	if x == t.NIL {
+		return
+	}
+
+	t.freeTree(x.left)
+	t.freeTree(x.right)
+	t.freeNode(x)
+}
+
+// clone copies the subtree rooted at x into the tree c, the copy of x gets
+// parent as its parent.
+func (t *Rbtree) clone(c *Rbtree, x, parent *Node) *Node {
+	if x == t.NIL {
+		return c.NIL
+	}
+
+	y := c.newNode(x.Item)
+	y.parent, y.color, y.size, y.sum = parent, x.color, x.size, x.sum
+	y.left = t.clone(c, x.left, y)
+	y.right = t.clone(c, x.right, y)
+	return y
+}
+
+// build replaces all the nodes of the tree by a balanced tree of the items,
+// which must be in strictly ascending order.
+//
+// The middle item becomes the root and both halfs are built the same way,
+// hence all the leaves are on the last two levels. Painting the nodes on the
+// last level RED and all the others BLACK satisfies all the properties.
+func (t *Rbtree) build(items []Item) {
+	// The nodes are all new, so are their accesses.
+	if t.lru != nil {
+		t.lru.reset()
+	}
+	t.root = t.buildNode(items, t.NIL, 0, bits.Len(uint(len(items)))-1)
+	t.count = len(items)
+}
+
+func (t *Rbtree) buildNode(items []Item, parent *Node, depth, last int) *Node {
+	if len(items) == 0 {
+		return t.NIL
+	}
+
+	mid := (len(items) - 1) / 2
+	x := t.newNode(items[mid])
+	x.parent, x.color, x.size = parent, BLACK, len(items)
+	if depth == last && depth > 0 {
+		x.color = RED
+	}
+	x.left = t.buildNode(items[:mid], x, depth+1, last)
+	x.right = t.buildNode(items[mid+1:], x, depth+1, last)
+	x.sum += x.left.sum + x.right.sum
+	return x
 }
 
 func (t *Rbtree) leftRotate(x *Node) {
 	// Since we are doing the left rotation, the right child should *NOT* nil.
-	if x.Right == t.NIL {
+	if x.right == t.NIL {
 		return
 	}
 
@@ -80,30 +419,39 @@ func (t *Rbtree) leftRotate(x *Node) {
 	//          β  γ                         α  β
 	//
 	// It should be note that during the rotating we do not change
-	// the Nodes' color.
+	// the Nodes' color. The subtree rooted at Y gets the same size
+	// as the one rooted at X before, only X needs to be recounted.
 	//
-	y := x.Right
-	x.Right = y.Left
-	if y.Left != t.NIL {
-		y.Left.Parent = x
+	y := x.right
+	x.right = y.left
+	if y.left != t.NIL {
+		y.left.parent = x
 	}
-	y.Parent = x.Parent
+	y.parent = x.parent
 
-	if x.Parent == t.NIL {
+	if x.parent == t.NIL {
 		t.root = y
-	} else if x == x.Parent.Left {
-		x.Parent.Left = y
+	} else if x == x.parent.left {
+		x.parent.left = y
 	} else {
-		x.Parent.Right = y
+		x.parent.right = y
 	}
 
-	y.Left = x
-	x.Parent = y
+	y.left = x
+	x.parent = y
+
+	y.size = x.size
+	x.size = x.left.size + x.right.size + 1
+
+	// Unlike the sizes, the weights are summed up again up to the root since
+	// the float addition is not associative, y may not get the same sum
+	// as x before.
+	t.reweigh(x)
 }
 
 func (t *Rbtree) rightRotate(x *Node) {
 	// Since we are doing the right rotation, the left child should *NOT* nil.
-	if x.Left == t.NIL {
+	if x.left == t.NIL {
 		return
 	}
 
@@ -118,58 +466,92 @@ func (t *Rbtree) rightRotate(x *Node) {
 	//      α  β                                 β  γ
 	//
 	// It should be note that during the rotating we do not change
-	// the Nodes' color.
+	// the Nodes' color. The subtree rooted at Y gets the same size
+	// as the one rooted at X before, only X needs to be recounted.
 	//
-	y := x.Left
-	x.Left = y.Right
-	if y.Right != t.NIL {
-		y.Right.Parent = x
+	y := x.left
+	x.left = y.right
+	if y.right != t.NIL {
+		y.right.parent = x
 	}
-	y.Parent = x.Parent
+	y.parent = x.parent
 
-	if x.Parent == t.NIL {
+	if x.parent == t.NIL {
 		t.root = y
-	} else if x == x.Parent.Left {
-		x.Parent.Left = y
+	} else if x == x.parent.left {
+		x.parent.left = y
 	} else {
-		x.Parent.Right = y
+		x.parent.right = y
 	}
 
-	y.Right = x
-	x.Parent = y
+	y.right = x
+	x.parent = y
+
+	y.size = x.size
+	x.size = x.left.size + x.right.size + 1
+
+	// Unlike the sizes, the weights are summed up again up to the root since
+	// the float addition is not associative, y may not get the same sum
+	// as x before.
+	t.reweigh(x)
 }
 
-func (t *Rbtree) insert(z *Node) *Node {
+// insert inserts the item as a new RED node and returns it with true, or
+// returns the node of the first equal item with false if there is already
+// one. If the tree allows duplicates and always is set, the item is inserted
+// anyway, after all the equal ones.
+func (t *Rbtree) insert(item Item, always bool) (*Node, bool) {
 	x := t.root
 	y := t.NIL
+	found := t.NIL
+	left := false
 
 	for x != t.NIL {
 		y = x
-		if less(z.Item, x.Item) {
-			x = x.Left
-		} else if less(x.Item, z.Item) {
-			x = x.Right
+		if t.less(item, x.Item) {
+			x, left = x.left, true
+		} else if t.less(x.Item, item) {
+			x, left = x.right, false
+		} else if !t.dup {
+			t.touch(x)
+			return x, false
+		} else if always {
+			x, left = x.right, false
 		} else {
-			return x
+			// Keep looking for the first equal item on the left.
+			found = x
+			x, left = x.left, true
 		}
 	}
 
-	z.Parent = y
+	if found != t.NIL {
+		t.touch(found)
+		return found, false
+	}
+
+	z := t.newNode(item)
+	z.parent = y
 	if y == t.NIL {
 		t.root = z
-	} else if less(z.Item, y.Item) {
-		y.Left = z
+	} else if left {
+		y.left = z
 	} else {
-		y.Right = z
+		y.right = z
+	}
+
+	// The new node is a leaf, every ancestor gets one more descendant.
+	for p := y; p != t.NIL; p = p.parent {
+		p.size++
 	}
+	t.reweigh(y)
 
 	t.count++
 	t.insertFixup(z)
-	return z
+	return z, true
 }
 
 func (t *Rbtree) insertFixup(z *Node) {
-	for z.Parent.Color == RED {
+	for z.parent.color == RED {
 		//
 		// Howerver, we do not need the assertion of non-nil grandparent
 		// because
@@ -179,11 +561,11 @@ func (t *Rbtree) insertFixup(z *Node) {
 		// Since the color of the parent is RED, so the parent is not root
 		// and the grandparent must be exist.
 		//
-		if z.Parent == z.Parent.Parent.Left {
+		if z.parent == z.parent.parent.left {
 			// Take y as the uncle, although it can be NIL, in that case
 			// its color is BLACK
-			y := z.Parent.Parent.Right
-			if y.Color == RED {
+			y := z.parent.parent.right
+			if y.color == RED {
 				//
 				// Case 1:
 				// Parent and uncle are both RED, the grandparent must be BLACK
@@ -198,12 +580,12 @@ func (t *Rbtree) insertFixup(z *Node) {
 				//  5) Every simple path from root to leaves contains the same
 				//     number of black nodes.
 				//
-				z.Parent.Color = BLACK
-				y.Color = BLACK
-				z.Parent.Parent.Color = RED
-				z = z.Parent.Parent
+				z.parent.color = BLACK
+				y.color = BLACK
+				z.parent.parent.color = RED
+				z = z.parent.parent
 			} else {
-				if z == z.Parent.Right {
+				if z == z.parent.right {
 					//
 					// Case 2:
 					// Parent is RED and uncle is BLACK and the current node
@@ -214,7 +596,7 @@ func (t *Rbtree) insertFixup(z *Node) {
 					// violation of 4).
 					// The continuation into Case 3 will fix that.
 					//
-					z = z.Parent
+					z = z.parent
 					t.leftRotate(z)
 				}
 				//
@@ -230,29 +612,29 @@ func (t *Rbtree) insertFixup(z *Node) {
 				// discussed before, the grandparent is BLACK) and do a right
 				// rotation will fix that.
 				//
-				z.Parent.Color = BLACK
-				z.Parent.Parent.Color = RED
-				t.rightRotate(z.Parent.Parent)
+				z.parent.color = BLACK
+				z.parent.parent.color = RED
+				t.rightRotate(z.parent.parent)
 			}
 		} else { // same as then clause with "right" and "left" exchanged
-			y := z.Parent.Parent.Left
-			if y.Color == RED {
-				z.Parent.Color = BLACK
-				y.Color = BLACK
-				z.Parent.Parent.Color = RED
-				z = z.Parent.Parent
+			y := z.parent.parent.left
+			if y.color == RED {
+				z.parent.color = BLACK
+				y.color = BLACK
+				z.parent.parent.color = RED
+				z = z.parent.parent
 			} else {
-				if z == z.Parent.Left {
-					z = z.Parent
+				if z == z.parent.left {
+					z = z.parent
 					t.rightRotate(z)
 				}
-				z.Parent.Color = BLACK
-				z.Parent.Parent.Color = RED
-				t.leftRotate(z.Parent.Parent)
+				z.parent.color = BLACK
+				z.parent.parent.color = RED
+				t.leftRotate(z.parent.parent)
 			}
 		}
 	}
-	t.root.Color = BLACK
+	t.root.color = BLACK
 }
 
 // Just traverse the node from root to left recursively until left is NIL.
@@ -262,8 +644,8 @@ func (t *Rbtree) min(x *Node) *Node {
 		return t.NIL
 	}
 
-	for x.Left != t.NIL {
-		x = x.Left
+	for x.left != t.NIL {
+		x = x.left
 	}
 
 	return x
@@ -276,8 +658,8 @@ func (t *Rbtree) max(x *Node) *Node {
 		return t.NIL
 	}
 
-	for x.Right != t.NIL {
-		x = x.Right
+	for x.right != t.NIL {
+		x = x.right
 	}
 
 	return x
@@ -285,18 +667,146 @@ func (t *Rbtree) max(x *Node) *Node {
 
 func (t *Rbtree) search(x *Node) *Node {
 	p := t.root
+	found := t.NIL
 
 	for p != t.NIL {
-		if less(p.Item, x.Item) {
-			p = p.Right
-		} else if less(x.Item, p.Item) {
-			p = p.Left
+		if t.less(p.Item, x.Item) {
+			p = p.right
+		} else if t.less(x.Item, p.Item) {
+			p = p.left
+		} else if t.dup {
+			// There may be more equal items on the left.
+			found = p
+			p = p.left
+		} else {
+			return p
+		}
+	}
+
+	return found
+}
+
+// find returns the first node whose item is at the same position as key and
+// is equal to it according to t.equal, or NIL if there is no such node.
+func (t *Rbtree) find(key Item) *Node {
+	x := t.search(&Node{t.NIL, t.NIL, t.NIL, RED, 0, 0, key})
+	if t.equal == nil {
+		return x
+	}
+
+	for ; x != t.NIL && !t.less(key, x.Item); x = t.successor(x) {
+		if t.equal(key, x.Item) {
+			return x
+		}
+		if !t.dup {
+			break
+		}
+	}
+	return t.NIL
+}
+
+// floor returns the node which holds the largest item less or equal than
+// key, or NIL if there is no such node.
+func (t *Rbtree) floor(key Item) *Node {
+	x := t.root
+	y := t.NIL
+
+	for x != t.NIL {
+		if t.less(key, x.Item) {
+			x = x.left
+		} else {
+			y = x
+			x = x.right
+		}
+	}
+
+	return y
+}
+
+// ceiling returns the node which holds the smallest item greater or equal
+// than key, or NIL if there is no such node.
+func (t *Rbtree) ceiling(key Item) *Node {
+	x := t.root
+	y := t.NIL
+
+	for x != t.NIL {
+		if t.less(x.Item, key) {
+			x = x.right
+		} else {
+			y = x
+			x = x.left
+		}
+	}
+
+	return y
+}
+
+// lower returns the node which holds the largest item less than key, or NIL
+// if there is no such node.
+func (t *Rbtree) lower(key Item) *Node {
+	x := t.root
+	y := t.NIL
+
+	for x != t.NIL {
+		if t.less(x.Item, key) {
+			y = x
+			x = x.right
+		} else {
+			x = x.left
+		}
+	}
+
+	return y
+}
+
+// higher returns the node which holds the smallest item greater than key, or
+// NIL if there is no such node.
+func (t *Rbtree) higher(key Item) *Node {
+	x := t.root
+	y := t.NIL
+
+	for x != t.NIL {
+		if t.less(key, x.Item) {
+			y = x
+			x = x.left
+		} else {
+			x = x.right
+		}
+	}
+
+	return y
+}
+
+// owns returns whether the node n is in the tree, by climbing up to the
+// root. Unlike the nodes in a tree, the sentinels and the detached nodes
+// have no children at all, which stops the climb in another tree as well.
+func (t *Rbtree) owns(n *Node) bool {
+	for ; n.left != nil; n = n.parent {
+		if n.parent == t.NIL {
+			return n == t.root
+		}
+	}
+	return false
+}
+
+// selectNode returns the node which holds the k-th smallest item, counting
+// from 0, or NIL if k is out of range.
+func (t *Rbtree) selectNode(k int) *Node {
+	x := t.root
+
+	for x != t.NIL {
+		r := x.left.size
+		if k < r {
+			x = x.left
+		} else if k > r {
+			k -= r + 1
+			x = x.right
 		} else {
 			break
 		}
 	}
 
-	return p
+	return x
 }
 
 //TODO: Need Document
@@ -306,121 +816,197 @@ func (t *Rbtree) successor(x *Node) *Node {
 	}
 
 	// Get the minimum from the right sub-tree if it existed.
-	if x.Right != t.NIL {
-		return t.min(x.Right)
+	if x.right != t.NIL {
+		return t.min(x.right)
+	}
+
+	y := x.parent
+	for y != t.NIL && x == y.right {
+		x = y
+		y = y.parent
+	}
+	return y
+}
+
+// predecessor is the mirror of successor, it returns the node which holds
+// the largest item less than x's, or NIL if x is the minimum.
+func (t *Rbtree) predecessor(x *Node) *Node {
+	if x == t.NIL {
+		return t.NIL
+	}
+
+	// Get the maximum from the left sub-tree if it existed.
+	if x.left != t.NIL {
+		return t.max(x.left)
 	}
 
-	y := x.Parent
-	for y != t.NIL && x == y.Right {
+	y := x.parent
+	for y != t.NIL && x == y.left {
 		x = y
-		y = y.Parent
+		y = y.parent
 	}
 	return y
 }
 
 //TODO: Need Document
-func (t *Rbtree) delete(key *Node) *Node {
+func (t *Rbtree) delete(key *Node) Item {
 	z := t.search(key)
 
 	if z == t.NIL {
-		return t.NIL
+		return nil
 	}
-	ret := &Node{t.NIL, t.NIL, t.NIL, z.Color, z.Item}
+
+	return t.deleteNode(z)
+}
+
+// deleteNode removes the node z which is known to be in the tree, puts it
+// back to the pool if there is one and returns its item.
+func (t *Rbtree) deleteNode(z *Node) Item {
+	ret := t.unlinkNode(z)
+	t.freeNode(z)
+	return ret
+}
+
+// unlinkNode removes the node z which is known to be in the tree and returns
+// its item, z is left detached. If z has two children, the node of its
+// successor is spliced out and then takes the place of z, rather than moving
+// the item of the successor into z, so that every other node keeps carrying
+// its own item.
+func (t *Rbtree) unlinkNode(z *Node) Item {
+	ret := z.Item
 
 	var y *Node
 	var x *Node
 
-	if z.Left == t.NIL || z.Right == t.NIL {
+	if z.left == t.NIL || z.right == t.NIL {
 		y = z
 	} else {
 		y = t.successor(z)
 	}
 
-	if y.Left != t.NIL {
-		x = y.Left
+	if y.left != t.NIL {
+		x = y.left
 	} else {
-		x = y.Right
+		x = y.right
 	}
 
 	// Even if x is NIL, we do the assign. In that case all the NIL nodes will
 	// change from {nil, nil, nil, BLACK, nil} to {nil, nil, ADDR, BLACK, nil},
 	// but do not worry about that because it will not affect the compare
 	// between Node-X with Node-NIL
-	x.Parent = y.Parent
+	x.parent = y.parent
 
-	if y.Parent == t.NIL {
+	if y.parent == t.NIL {
 		t.root = x
-	} else if y == y.Parent.Left {
-		y.Parent.Left = x
+	} else if y == y.parent.left {
+		y.parent.left = x
 	} else {
-		y.Parent.Right = x
+		y.parent.right = x
+	}
+
+	// Node y has been spliced out, every ancestor of it loses one
+	// descendant. This must be done before the fixup since rotations
+	// recount the sizes from the children.
+	for p := y.parent; p != t.NIL; p = p.parent {
+		p.size--
 	}
 
+	color := y.color
 	if y != z {
-		z.Item = y.Item
+		// Move y into the place of z, with its color and size.
+		y.left, y.right, y.parent = z.left, z.right, z.parent
+		y.color, y.size = z.color, z.size
+		if y.left != t.NIL {
+			y.left.parent = y
+		}
+		if y.right != t.NIL {
+			y.right.parent = y
+		}
+		if x.parent == z {
+			x.parent = y
+		}
+
+		if z.parent == t.NIL {
+			t.root = y
+		} else if z == z.parent.left {
+			z.parent.left = y
+		} else {
+			z.parent.right = y
+		}
 	}
 
-	if y.Color == BLACK {
+	// The lowest subtree which has changed is the one of the parent of x,
+	// which is also in the place of z if y has been moved there.
+	t.reweigh(x.parent)
+
+	if color == BLACK {
 		t.deleteFixup(x)
 	}
 
 	t.count--
 
+	if t.lru != nil {
+		t.lru.remove(z)
+	}
+
+	// Detach z so that it can be told apart from the nodes in the tree.
+	z.left, z.right, z.parent = nil, nil, nil
+
 	return ret
 }
 
 func (t *Rbtree) deleteFixup(x *Node) {
-	for x != t.root && x.Color == BLACK {
-		if x == x.Parent.Left {
-			w := x.Parent.Right
-			if w.Color == RED {
-				w.Color = BLACK
-				x.Parent.Color = RED
-				t.leftRotate(x.Parent)
-				w = x.Parent.Right
+	for x != t.root && x.color == BLACK {
+		if x == x.parent.left {
+			w := x.parent.right
+			if w.color == RED {
+				w.color = BLACK
+				x.parent.color = RED
+				t.leftRotate(x.parent)
+				w = x.parent.right
 			}
-			if w.Left.Color == BLACK && w.Right.Color == BLACK {
-				w.Color = RED
-				x = x.Parent
+			if w.left.color == BLACK && w.right.color == BLACK {
+				w.color = RED
+				x = x.parent
 			} else {
-				if w.Right.Color == BLACK {
-					w.Left.Color = BLACK
-					w.Color = RED
+				if w.right.color == BLACK {
+					w.left.color = BLACK
+					w.color = RED
 					t.rightRotate(w)
-					w = x.Parent.Right
+					w = x.parent.right
 				}
-				w.Color = x.Parent.Color
-				x.Parent.Color = BLACK
-				w.Right.Color = BLACK
-				t.leftRotate(x.Parent)
+				w.color = x.parent.color
+				x.parent.color = BLACK
+				w.right.color = BLACK
+				t.leftRotate(x.parent)
 				// this is to exit while loop
 				x = t.root
 			}
 		} else { // the code below is has left and right switched from above
-			w := x.Parent.Left
-			if w.Color == RED {
-				w.Color = BLACK
-				x.Parent.Color = RED
-				t.rightRotate(x.Parent)
-				w = x.Parent.Left
+			w := x.parent.left
+			if w.color == RED {
+				w.color = BLACK
+				x.parent.color = RED
+				t.rightRotate(x.parent)
+				w = x.parent.left
 			}
-			if w.Left.Color == BLACK && w.Right.Color == BLACK {
-				w.Color = RED
-				x = x.Parent
+			if w.left.color == BLACK && w.right.color == BLACK {
+				w.color = RED
+				x = x.parent
 			} else {
-				if w.Left.Color == BLACK {
-					w.Right.Color = BLACK
-					w.Color = RED
+				if w.left.color == BLACK {
+					w.right.color = BLACK
+					w.color = RED
 					t.leftRotate(w)
-					w = x.Parent.Left
+					w = x.parent.left
 				}
-				w.Color = x.Parent.Color
-				x.Parent.Color = BLACK
-				w.Left.Color = BLACK
-				t.rightRotate(x.Parent)
+				w.color = x.parent.color
+				x.parent.color = BLACK
+				w.left.color = BLACK
+				t.rightRotate(x.parent)
 				x = t.root
 			}
 		}
 	}
-	x.Color = BLACK
+	x.color = BLACK
 }
@@ -5,8 +5,14 @@
 package rbtree
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestInsertAndDelete(t *testing.T) {
@@ -18,7 +24,7 @@ func TestInsertAndDelete(t *testing.T) {
 		rbt.Insert(Int(m))
 		m++
 	}
-	if rbt.Len() != uint(n) {
+	if rbt.Len() != n {
 		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), n)
 	}
 
@@ -93,6 +99,422 @@ func TestInsertDup(t *testing.T) {
 	}
 }
 
+func TestForEach(t *testing.T) {
+	rbt := New()
+	rbt.ForEach(func(i Item) {
+		t.Errorf("ForEach visited %v in an empty tree", i)
+	})
+	rbt.ForEachDescending(func(i Item) {
+		t.Errorf("ForEachDescending visited %v in an empty tree", i)
+	})
+
+	perm := rand.Perm(100)
+	for _, v := range perm {
+		rbt.Insert(Int(v))
+	}
+
+	var ret []Item
+	rbt.ForEach(func(i Item) {
+		ret = append(ret, i)
+	})
+	if len(ret) != 100 {
+		t.Fatalf("ForEach visited %d items, expect %d", len(ret), 100)
+	}
+	for i, item := range ret {
+		if item != Int(i) {
+			t.Errorf("item %d is %v, expect %v", i, item, Int(i))
+		}
+	}
+
+	ret = nil
+	rbt.ForEachDescending(func(i Item) {
+		ret = append(ret, i)
+	})
+	if len(ret) != 100 {
+		t.Fatalf("ForEachDescending visited %d items, expect %d", len(ret), 100)
+	}
+	for i, item := range ret {
+		if item != Int(99-i) {
+			t.Errorf("item %d is %v, expect %v", i, item, Int(99-i))
+		}
+	}
+}
+
+func TestLevelOrder(t *testing.T) {
+	rbt := New()
+	rbt.LevelOrder(func(i Item, level int) bool {
+		t.Errorf("LevelOrder visited %v in an empty tree", i)
+		return true
+	})
+
+	for _, v := range rand.Perm(1000) {
+		rbt.Insert(Int(v))
+	}
+
+	var sizes []int
+	prev := Item(nil)
+	rbt.LevelOrder(func(i Item, level int) bool {
+		if level == 0 {
+			if root, _ := rbt.Root(); i != root.Item {
+				t.Errorf("level 0 is %v, expect the root %v", i, root.Item)
+			}
+		}
+		if level == len(sizes) {
+			sizes = append(sizes, 0)
+			prev = nil
+		} else if level != len(sizes)-1 {
+			t.Fatalf("%v is at level %d after level %d", i, level, len(sizes)-1)
+		}
+		if prev != nil && !prev.Less(i) {
+			t.Errorf("%v is visited after %v in level %d", i, prev, level)
+		}
+		prev = i
+		sizes[level]++
+		return true
+	})
+
+	if len(sizes) != rbt.Height() {
+		t.Errorf("LevelOrder visited %d levels, expect %d", len(sizes), rbt.Height())
+	}
+	total := 0
+	for level, size := range sizes {
+		// All the paths have as many black nodes, so the levels above the
+		// black height are full.
+		if size > 1<<level || level < rbt.BlackHeight() && size != 1<<level {
+			t.Errorf("level %d has %d items", level, size)
+		}
+		total += size
+	}
+	if total != rbt.Len() {
+		t.Errorf("LevelOrder visited %d items, expect %d", total, rbt.Len())
+	}
+
+	n := 0
+	rbt.LevelOrder(func(i Item, level int) bool {
+		n++
+		return n < 5
+	})
+	if n != 5 {
+		t.Errorf("LevelOrder visited %d items after stopping, expect %d", n, 5)
+	}
+}
+
+func TestAscendMorris(t *testing.T) {
+	rbt := New()
+	rbt.AscendMorris(func(i Item) bool {
+		t.Errorf("unexpected %v in empty tree", i)
+		return true
+	})
+
+	for _, v := range rand.Perm(1000) {
+		rbt.Insert(Int(v))
+	}
+
+	// The children of all the nodes, which are compared without walking the
+	// tree since a thread left behind would make it cyclic.
+	nodes := rbt.SliceAscend()
+	children := make([][2]*Node, len(nodes))
+	for i, n := range nodes {
+		children[i] = [2]*Node{n.left, n.right}
+	}
+	restored := func(when string) {
+		for i, n := range nodes {
+			if n.left != children[i][0] || n.right != children[i][1] {
+				t.Fatalf("node %v is not restored after %s", n.Item, when)
+			}
+		}
+		if err := rbt.CheckInvariants(); err != nil {
+			t.Fatalf("%s: %v", when, err)
+		}
+	}
+
+	var ret []Item
+	rbt.AscendMorris(func(i Item) bool {
+		ret = append(ret, i)
+		return true
+	})
+	if !reflect.DeepEqual(ret, items(rbt)) {
+		t.Errorf("expected %v but got %v", items(rbt), ret)
+	}
+	restored("a full traversal")
+
+	for _, stop := range []Int{0, 1, 250, 499, 500, 998, 999} {
+		n := 0
+		rbt.AscendMorris(func(i Item) bool {
+			if i != Int(n) {
+				t.Errorf("item %d is %v, expect %v", n, i, Int(n))
+			}
+			n++
+			return i != stop
+		})
+		if n != int(stop)+1 {
+			t.Errorf("AscendMorris visited %d items, expect %d", n, stop+1)
+		}
+		restored(fmt.Sprintf("stopping at %v", stop))
+	}
+
+	func() {
+		defer func() { recover() }()
+		rbt.AscendMorris(func(i Item) bool {
+			if i == Int(321) {
+				panic("bad item")
+			}
+			return true
+		})
+	}()
+	restored("a panic")
+}
+
+func TestAscendIndexed(t *testing.T) {
+	rbt := New()
+	for _, v := range rand.Perm(50) {
+		rbt.Insert(Int(v * 2))
+	}
+
+	n := 0
+	rbt.AscendIndexed(func(index int, item Item) bool {
+		if index != n || item != Int(index*2) {
+			t.Errorf("AscendIndexed passed %d, %v, expect %d, %v", index, item, n, Int(n*2))
+		}
+		n++
+		return true
+	})
+	if n != 50 {
+		t.Errorf("AscendIndexed visited %d items, expect %d", n, 50)
+	}
+
+	n = 0
+	rbt.AscendIndexed(func(index int, item Item) bool {
+		n++
+		return index < 9
+	})
+	if n != 10 {
+		t.Errorf("AscendIndexed visited %d items after stopping, expect %d", n, 10)
+	}
+}
+
+func TestAscendStride(t *testing.T) {
+	rbt := newIntTree(intRange(1, 11, 1)...)
+
+	tests := []struct {
+		stride   int
+		expected []Item
+	}{
+		{1, []Item{Int(1), Int(2), Int(3), Int(4), Int(5), Int(6), Int(7), Int(8), Int(9), Int(10)}},
+		{2, []Item{Int(1), Int(3), Int(5), Int(7), Int(9)}},
+		{3, []Item{Int(1), Int(4), Int(7), Int(10)}},
+		{20, []Item{Int(1)}},
+		{0, []Item{Int(1), Int(2), Int(3), Int(4), Int(5), Int(6), Int(7), Int(8), Int(9), Int(10)}},
+	}
+
+	for _, test := range tests {
+		var ret []Item
+		rbt.AscendStride(test.stride, func(i Item) bool {
+			ret = append(ret, i)
+			return true
+		})
+		if !reflect.DeepEqual(ret, test.expected) {
+			t.Errorf("stride %d: expected %v but got %v", test.stride, test.expected, ret)
+		}
+	}
+
+	var ret []Item
+	rbt.AscendStride(3, func(i Item) bool {
+		ret = append(ret, i)
+		return len(ret) < 2
+	})
+	if expected := []Item{Int(1), Int(4)}; !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+}
+
+func TestFold(t *testing.T) {
+	rbt := New()
+	if acc := rbt.Fold(42, nil); acc != 42 {
+		t.Errorf("Fold() = %v on an empty tree, expect %v", acc, 42)
+	}
+
+	for _, v := range rand.Perm(100) {
+		rbt.Insert(Int(v))
+	}
+
+	sum := rbt.Fold(0, func(acc interface{}, item Item) interface{} {
+		return acc.(int) + int(item.(Int))
+	})
+	if sum != 4950 {
+		t.Errorf("Fold() = %v, expect %v", sum, 4950)
+	}
+
+	str := New()
+	for _, s := range []string{"c", "a", "d", "b"} {
+		str.Insert(String(s))
+	}
+	concat := str.Fold("", func(acc interface{}, item Item) interface{} {
+		return acc.(string) + string(item.(String))
+	})
+	if concat != "abcd" {
+		t.Errorf("Fold() = %q, expect %q", concat, "abcd")
+	}
+}
+
+func TestInsertNil(t *testing.T) {
+	rbt := New()
+	for i := 0; i < 10; i++ {
+		if !rbt.Insert(Int(i)) {
+			t.Errorf("Insert(%d) = false, expect true", i)
+		}
+	}
+	if rbt.Insert(Int(5)) {
+		t.Errorf("Insert(5) = true, expect false")
+	}
+
+	if rbt.Insert(nil) {
+		t.Errorf("Insert(nil) = true, expect false")
+	}
+	if rbt.Len() != 10 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 10)
+	}
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The tree still works as usual afterwards.
+	if !rbt.Insert(Int(10)) || rbt.Delete(Int(3)) == nil {
+		t.Errorf("tree.Insert or tree.Delete failed after Insert(nil)")
+	}
+	if expected := items(newIntTree(0, 1, 2, 4, 5, 6, 7, 8, 9, 10)); !reflect.DeepEqual(items(rbt), expected) {
+		t.Errorf("expected %v but got %v", expected, items(rbt))
+	}
+}
+
+func TestAscendAll(t *testing.T) {
+	rbt := New()
+	for _, v := range rand.Perm(100) {
+		rbt.Insert(Int(v))
+	}
+	for v := 0; v < 100; v += 4 {
+		rbt.Delete(Int(v))
+	}
+
+	var ret []Item
+	rbt.AscendAll(func(i Item) bool {
+		ret = append(ret, i)
+		return true
+	})
+	var expected []Item
+	for _, node := range rbt.SliceAscend() {
+		expected = append(expected, node.Item)
+	}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+}
+
+func TestAscendAllAndDescendAll(t *testing.T) {
+	rbt := New()
+	rbt.AscendAll(func(i Item) bool {
+		t.Errorf("unexpected %v in empty tree", i)
+		return true
+	})
+	rbt.DescendAll(func(i Item) bool {
+		t.Errorf("unexpected %v in empty tree", i)
+		return true
+	})
+
+	for _, s := range []string{"tree", "a", "red", "black", "go", "b"} {
+		rbt.Insert(String(s))
+	}
+
+	var ret []Item
+	rbt.AscendAll(func(i Item) bool {
+		ret = append(ret, i)
+		return true
+	})
+	expected := []Item{String("a"), String("b"), String("black"), String("go"), String("red"), String("tree")}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	ret = nil
+	rbt.DescendAll(func(i Item) bool {
+		ret = append(ret, i)
+		return true
+	})
+	expected = []Item{String("tree"), String("red"), String("go"), String("black"), String("b"), String("a")}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	ret = nil
+	rbt.DescendAll(func(i Item) bool {
+		ret = append(ret, i)
+		return len(ret) < 2
+	})
+	if expected := []Item{String("tree"), String("red")}; !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+}
+
+func TestAllAndBackward(t *testing.T) {
+	rbt := New()
+	for item := range rbt.All() {
+		t.Errorf("unexpected %v in empty tree", item)
+	}
+	for item := range rbt.Backward() {
+		t.Errorf("unexpected %v in empty tree", item)
+	}
+
+	for _, v := range rand.Perm(100) {
+		rbt.Insert(Int(v))
+	}
+
+	n := 0
+	for item := range rbt.All() {
+		if item != Int(n) {
+			t.Errorf("item %d is %v, expect %v", n, item, Int(n))
+		}
+		n++
+	}
+	if n != 100 {
+		t.Errorf("All() visited %d items, expect %d", n, 100)
+	}
+
+	n = 0
+	for item := range rbt.Backward() {
+		if item != Int(99-n) {
+			t.Errorf("item %d is %v, expect %v", n, item, Int(99-n))
+		}
+		n++
+	}
+	if n != 100 {
+		t.Errorf("Backward() visited %d items, expect %d", n, 100)
+	}
+
+	// The loops must not go on after a break, the range-over-func runtime
+	// panics if yield is called again.
+	var ret []Item
+	for item := range rbt.All() {
+		if item == Int(3) {
+			break
+		}
+		ret = append(ret, item)
+	}
+	if expected := []Item{Int(0), Int(1), Int(2)}; !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	ret = nil
+	for item := range rbt.Backward() {
+		if item == Int(96) {
+			break
+		}
+		ret = append(ret, item)
+	}
+	if expected := []Item{Int(99), Int(98), Int(97)}; !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+}
+
 func TestDescend(t *testing.T) {
 	rbt := New()
 
@@ -132,18 +554,45 @@ func TestGet(t *testing.T) {
 	rbt.Insert(Int(2))
 	rbt.Insert(Int(3))
 
-	no := rbt.Get(Int(100))
-	ok := rbt.Get(Int(1))
-
-	if no != nil {
+	if _, ok := rbt.Get(Int(100)); ok {
 		t.Errorf("100 is expect not exists")
 	}
 
-	if ok == nil {
+	if item, ok := rbt.Get(Int(1)); !ok || item != Int(1) {
 		t.Errorf("1 is expect exists")
 	}
 }
 
+func TestGetStoredItem(t *testing.T) {
+	rbt := New()
+
+	items := []*testStruct{
+		{1, "this"},
+		{2, "is"},
+		{3, "a"},
+		{4, "test"},
+	}
+	for i := range items {
+		rbt.Insert(items[i])
+	}
+
+	for i := range items {
+		key := &testStruct{items[i].id, ""}
+		item, ok := rbt.Get(key)
+		if !ok {
+			t.Errorf("%d is expect exists", key.id)
+			continue
+		}
+		if item.(*testStruct) != items[i] {
+			t.Errorf("tree.Get = {id: %d, text: %s}, expect {id %d, text %s}", item.(*testStruct).id, item.(*testStruct).text, items[i].id, items[i].text)
+		}
+	}
+
+	if _, ok := rbt.Get(&testStruct{5, "test"}); ok {
+		t.Errorf("5 is expect not exists")
+	}
+}
+
 func TestAscend(t *testing.T) {
 	rbt := New()
 
@@ -152,10 +601,12 @@ func TestAscend(t *testing.T) {
 	rbt.Insert(String("c"))
 	rbt.Insert(String("d"))
 
-	rbt.Delete(rbt.Min())
+	min, _ := rbt.Min()
+	rbt.Delete(min)
 
+	min, _ = rbt.Min()
 	var ret []Item
-	rbt.Ascend(rbt.Min(), func(i Item) bool {
+	rbt.Ascend(min, func(i Item) bool {
 		ret = append(ret, i)
 		return true
 	})
@@ -174,8 +625,164 @@ func TestMax(t *testing.T) {
 	rbt.Insert(String("a"))
 
 	expected := String("z")
-	if rbt.Max() != expected {
-		t.Errorf("expected Max of tree as %v but got %v", expected, rbt.Max())
+	if max, ok := rbt.Max(); !ok || max != expected {
+		t.Errorf("expected Max of tree as %v but got %v", expected, max)
+	}
+}
+
+func TestAscendGreaterAndLess(t *testing.T) {
+	rbt := New()
+	for i := 0; i < 10; i++ {
+		rbt.Insert(Int(i))
+	}
+
+	var ret []Item
+	collect := func(i Item) bool {
+		ret = append(ret, i)
+		return true
+	}
+
+	rbt.AscendGreater(Int(6), collect)
+	if expected := []Item{Int(7), Int(8), Int(9)}; !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	ret = nil
+	rbt.AscendLess(Int(3), collect)
+	if expected := []Item{Int(0), Int(1), Int(2)}; !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	ret = nil
+	rbt.AscendGreater(Int(9), collect)
+	rbt.AscendLess(Int(0), collect)
+	if len(ret) != 0 {
+		t.Errorf("expected nothing but got %v", ret)
+	}
+
+	ret = nil
+	rbt.AscendLess(Int(100), func(i Item) bool {
+		ret = append(ret, i)
+		return len(ret) < 2
+	})
+	if expected := []Item{Int(0), Int(1)}; !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+}
+
+func TestAscendWhile(t *testing.T) {
+	rbt := New()
+	for _, v := range rand.Perm(100) {
+		rbt.Insert(Int(v))
+	}
+
+	var ret []Item
+	var tested []Item
+	rbt.AscendWhile(Int(10), func(i Item) bool {
+		tested = append(tested, i)
+		return i.(Int) <= 15
+	}, func(i Item) {
+		ret = append(ret, i)
+	})
+	if expected := []Item{Int(10), Int(11), Int(12), Int(13), Int(14), Int(15)}; !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+	// The predicate is not called again after the first item exceeding 15.
+	if last := tested[len(tested)-1]; len(tested) != 7 || last != Int(16) {
+		t.Errorf("the predicate is called %d times up to %v, expect %d up to %v", len(tested), last, 7, 16)
+	}
+
+	ret = nil
+	rbt.AscendWhile(Int(95), func(Item) bool { return true }, func(i Item) {
+		ret = append(ret, i)
+	})
+	if expected := []Item{Int(95), Int(96), Int(97), Int(98), Int(99)}; !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	rbt.AscendWhile(Int(0), func(Item) bool { return false }, func(i Item) {
+		t.Errorf("unexpected %v visited", i)
+	})
+	rbt.AscendWhile(Int(100), func(Item) bool { return true }, func(i Item) {
+		t.Errorf("unexpected %v visited", i)
+	})
+}
+
+func TestAscendFromRank(t *testing.T) {
+	rbt := New()
+	for _, v := range rand.Perm(100) {
+		rbt.Insert(Int(v + 1))
+	}
+
+	// Page through the items 10 at a time.
+	for _, start := range []int{0, 10, 35, 90, 95} {
+		var page []Item
+		rbt.AscendFromRank(start, func(i Item) bool {
+			page = append(page, i)
+			return len(page) < 10
+		})
+
+		var expected []Item
+		for v := start + 1; v <= 100 && len(expected) < 10; v++ {
+			expected = append(expected, Int(v))
+		}
+		if !reflect.DeepEqual(page, expected) {
+			t.Errorf("rank %d: expected %v but got %v", start, expected, page)
+		}
+	}
+
+	rbt.AscendFromRank(100, func(i Item) bool {
+		t.Errorf("unexpected %v after the last rank", i)
+		return true
+	})
+
+	var first Item
+	rbt.AscendFromRank(-1, func(i Item) bool {
+		first = i
+		return false
+	})
+	if first != Int(1) {
+		t.Errorf("AscendFromRank(-1) starts at %v, expect %v", first, Int(1))
+	}
+}
+
+func TestAscendSubtree(t *testing.T) {
+	rbt := New()
+	for _, v := range rand.Perm(100) {
+		rbt.Insert(Int(v))
+	}
+
+	for _, node := range rbt.SliceAscend() {
+		var ret []Item
+		rbt.AscendSubtree(node.Item, func(i Item) bool {
+			ret = append(ret, i)
+			return true
+		})
+		if len(ret) != node.size {
+			t.Fatalf("subtree of %v has %d items, expect %d", node.Item, len(ret), node.size)
+		}
+
+		// The subtree is a contiguous block of the items.
+		first, _ := rbt.Rank(ret[0])
+		for i, item := range ret {
+			if item != Int(first+i) {
+				t.Fatalf("subtree of %v is %v, expect a contiguous block", node.Item, ret)
+			}
+		}
+	}
+
+	rbt.AscendSubtree(Int(100), func(i Item) bool {
+		t.Errorf("unexpected %v in the subtree of an absent item", i)
+		return true
+	})
+
+	var ret []Item
+	rbt.AscendSubtree(rbt.root.Item, func(i Item) bool {
+		ret = append(ret, i)
+		return true
+	})
+	if !reflect.DeepEqual(ret, items(rbt)) {
+		t.Errorf("expected %v but got %v", items(rbt), ret)
 	}
 }
 
@@ -198,3 +805,592 @@ func TestAscendRange(t *testing.T) {
 		t.Errorf("expected %v but got %v", expected, ret)
 	}
 }
+
+func TestAscendInclusive(t *testing.T) {
+	rbt := New()
+
+	for i := 0; i < 20; i += 2 {
+		rbt.Insert(Int(i))
+	}
+
+	tests := []struct {
+		lo, hi   Int
+		expected []Item
+	}{
+		// Both endpoints are present.
+		{4, 10, []Item{Int(4), Int(6), Int(8), Int(10)}},
+		{6, 6, []Item{Int(6)}},
+		{0, 18, []Item{Int(0), Int(2), Int(4), Int(6), Int(8), Int(10), Int(12), Int(14), Int(16), Int(18)}},
+		// Both endpoints are absent.
+		{3, 11, []Item{Int(4), Int(6), Int(8), Int(10)}},
+		{-5, 1, []Item{Int(0)}},
+		{7, 7, nil},
+		{10, 4, nil},
+	}
+
+	for _, test := range tests {
+		var ret []Item
+		rbt.AscendInclusive(test.lo, test.hi, func(i Item) bool {
+			ret = append(ret, i)
+			return true
+		})
+		if !reflect.DeepEqual(ret, test.expected) {
+			t.Errorf("[%d, %d]: expected %v but got %v", test.lo, test.hi, test.expected, ret)
+		}
+	}
+}
+
+func TestDescendRange(t *testing.T) {
+	rbt := New()
+
+	for i := 0; i < 10; i++ {
+		rbt.Insert(Int(i))
+	}
+
+	tests := []struct {
+		le, gt   Int
+		expected []Item
+	}{
+		{7, 3, []Item{Int(7), Int(6), Int(5), Int(4)}},
+		{100, 7, []Item{Int(9), Int(8)}},
+		{2, -100, []Item{Int(2), Int(1), Int(0)}},
+		{5, 5, nil},
+		{3, 7, nil},
+		{-1, -5, nil},
+	}
+
+	for _, test := range tests {
+		var ret []Item
+		rbt.DescendRange(test.le, test.gt, func(i Item) bool {
+			ret = append(ret, i)
+			return true
+		})
+		if !reflect.DeepEqual(ret, test.expected) {
+			t.Errorf("DescendRange(%v, %v): expected %v but got %v", test.le, test.gt, test.expected, ret)
+		}
+	}
+
+	var ret []Item
+	rbt.DescendRange(Int(9), Int(0), func(i Item) bool {
+		ret = append(ret, i)
+		return len(ret) < 3
+	})
+	expected := []Item{Int(9), Int(8), Int(7)}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	if ret := New().Collect(); ret == nil || len(ret) != 0 {
+		t.Errorf("Collect() = %#v on an empty tree, expect an empty slice", ret)
+	}
+
+	rbt := New()
+	for _, v := range rand.Perm(100) {
+		rbt.Insert(Int(v))
+	}
+
+	var expected []Item
+	for _, node := range rbt.SliceAscend() {
+		expected = append(expected, node.Item)
+	}
+	if ret := rbt.Collect(); !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+}
+
+func TestAppendAscend(t *testing.T) {
+	rbt := New()
+	for _, v := range rand.Perm(100) {
+		rbt.Insert(Int(v))
+	}
+
+	dst := []Item{String("head")}
+	ret := rbt.AppendAscend(dst)
+	if len(ret) != 101 || ret[0] != String("head") {
+		t.Fatalf("AppendAscend() = %v, expect head and 100 items", ret)
+	}
+	if !reflect.DeepEqual(ret[1:], rbt.Collect()) {
+		t.Errorf("expected %v but got %v", rbt.Collect(), ret[1:])
+	}
+
+	// The buffer is reused if it is large enough.
+	buf := make([]Item, 0, 200)
+	ret = rbt.AppendAscend(buf)
+	if len(ret) != 100 || &ret[:1][0] != &buf[:1][0] {
+		t.Errorf("AppendAscend() does not reuse the buffer")
+	}
+	if ret = New().AppendAscend(ret[:0]); len(ret) != 0 {
+		t.Errorf("AppendAscend() = %v on an empty tree", ret)
+	}
+}
+
+func TestSliceDescend(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+	}{
+		{"empty", 0},
+		{"single", 1},
+		{"two", 2},
+		{"hundred", 100},
+	}
+
+	for _, test := range tests {
+		rbt := New()
+		for _, v := range rand.Perm(test.n) {
+			rbt.Insert(Int(v + 1))
+		}
+
+		asc := rbt.SliceAscend()
+		desc := rbt.SliceDescend()
+		if len(desc) != test.n {
+			t.Errorf("%s: len(SliceDescend()) = %d, expect %d", test.name, len(desc), test.n)
+			continue
+		}
+		for i := range desc {
+			if desc[i] != asc[len(asc)-1-i] {
+				t.Errorf("%s: SliceDescend()[%d] = %v, expect %v", test.name, i, desc[i].Item, asc[len(asc)-1-i].Item)
+			}
+		}
+	}
+}
+
+func TestSliceDescendFirstN(t *testing.T) {
+	rbt := New()
+	for _, v := range rand.Perm(50) {
+		rbt.Insert(Int(v))
+	}
+
+	desc := rbt.SliceDescend()
+	n := len(desc)
+	for _, k := range []int{0, 1, 2, 10, n - 1, n, n + 5} {
+		ret := rbt.SliceDescendFirstN(k)
+
+		expected := desc
+		if k < n {
+			expected = desc[:k]
+		}
+		if !reflect.DeepEqual(ret, expected) {
+			t.Errorf("SliceDescendFirstN(%d) returned %d nodes, expect the first %d of SliceDescend()", k, len(ret), len(expected))
+		}
+	}
+}
+
+func TestReverse(t *testing.T) {
+	natural := func(a, b Item) int { return int(a.(Int)) - int(b.(Int)) }
+
+	for _, rbt := range []*Rbtree{NewReverse(), NewWithComparator(Reverse(natural))} {
+		for _, v := range rand.Perm(10) {
+			rbt.Insert(Int(v))
+		}
+		if err := rbt.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+
+		if min, _ := rbt.Min(); min != Int(9) {
+			t.Errorf("expected Min of tree as %v but got %v", 9, min)
+		}
+		var ret []Item
+		rbt.Ascend(Int(2), func(i Item) bool {
+			ret = append(ret, i)
+			return true
+		})
+		if expected := []Item{Int(2), Int(1), Int(0)}; !reflect.DeepEqual(ret, expected) {
+			t.Errorf("expected %v but got %v", expected, ret)
+		}
+	}
+
+	if c := Reverse(Reverse(natural)); c(Int(1), Int(2)) >= 0 {
+		t.Errorf("Reverse(Reverse(cmp))(1, 2) = %d, expect a negative number", c(Int(1), Int(2)))
+	}
+}
+
+func TestNewWithComparator(t *testing.T) {
+	rbt := NewWithComparator(func(a, b Item) int {
+		return int(b.(Int)) - int(a.(Int))
+	})
+
+	for _, v := range rand.Perm(10) {
+		rbt.Insert(Int(v))
+	}
+	rbt.Insert(Int(5))
+	if rbt.Len() != 10 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 10)
+	}
+
+	var ret []Item
+	rbt.Ascend(Int(6), func(i Item) bool {
+		ret = append(ret, i)
+		return true
+	})
+	expected := []Item{Int(6), Int(5), Int(4), Int(3), Int(2), Int(1), Int(0)}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	if min, _ := rbt.Min(); min != Int(9) {
+		t.Errorf("expected Min of tree as %v but got %v", 9, min)
+	}
+	if item, _ := rbt.Get(Int(3)); item != Int(3) {
+		t.Errorf("expected %v but got %v", 3, item)
+	}
+}
+
+func TestGetOrInsert(t *testing.T) {
+	rbt := New()
+
+	first := &testStruct{1, "first"}
+	item, found := rbt.GetOrInsert(first)
+	if found || item.(*testStruct) != first {
+		t.Errorf("GetOrInsert(first) = %v, %v, expect the inserted item and false", item, found)
+	}
+	if rbt.Len() != 1 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 1)
+	}
+
+	item, found = rbt.GetOrInsert(&testStruct{1, "second"})
+	if !found || item.(*testStruct) != first {
+		t.Errorf("GetOrInsert(second) = %v, %v, expect the first item and true", item, found)
+	}
+	if rbt.Len() != 1 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 1)
+	}
+
+	item, found = rbt.GetOrInsert(&testStruct{2, "third"})
+	if found || item.(*testStruct).text != "third" {
+		t.Errorf("GetOrInsert(third) = %v, %v, expect the inserted item and false", item, found)
+	}
+	if rbt.Len() != 2 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 2)
+	}
+}
+
+func TestRootLeftRight(t *testing.T) {
+	if n, ok := New().Root(); ok || n != nil {
+		t.Errorf("Root() = %v, %v on an empty tree, expect nil, false", n, ok)
+	}
+
+	rbt := New()
+	for _, v := range rand.Perm(100) {
+		rbt.Insert(Int(v))
+	}
+
+	var walk func(n *Node) []*Node
+	walk = func(n *Node) []*Node {
+		if n == nil {
+			return nil
+		}
+		ret := append(walk(n.Left()), n)
+		return append(ret, walk(n.Right())...)
+	}
+
+	root, ok := rbt.Root()
+	if !ok || root.IsNil() {
+		t.Fatalf("Root() = %v, %v, expect a node", root, ok)
+	}
+	if ret := walk(root); !reflect.DeepEqual(ret, rbt.SliceAscend()) {
+		t.Errorf("walking from the root does not match SliceAscend")
+	}
+
+	if !rbt.NIL.IsNil() || !(*Node)(nil).IsNil() {
+		t.Errorf("IsNil() = false for NIL or nil")
+	}
+	if rbt.NIL.Left() != nil || rbt.NIL.Right() != nil {
+		t.Errorf("NIL has children")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	rbt := New()
+
+	items := []*testStruct{
+		{1, "this"},
+		{2, "is"},
+		{3, "a"},
+		{4, "test"},
+	}
+	for i := range items {
+		rbt.Insert(items[i])
+	}
+
+	// Insert replaces the stored item as well.
+	inserted := &testStruct{3, "not"}
+	rbt.Insert(inserted)
+	if item, _ := rbt.Get(&testStruct{3, ""}); item.(*testStruct) != inserted {
+		t.Errorf("tree.Get = %v, expect %v", item, inserted)
+	}
+	if rbt.Len() != len(items) {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), len(items))
+	}
+
+	before := rbt.SliceAscend()
+
+	updated := &testStruct{3, "the"}
+	old, replaced := rbt.Replace(updated)
+	if !replaced || old.(*testStruct) != inserted {
+		t.Errorf("Replace() = %v, %v, expect %v, true", old, replaced, inserted)
+	}
+	if item, _ := rbt.Get(&testStruct{3, ""}); item.(*testStruct) != updated {
+		t.Errorf("tree.Get = %v, expect %v", item, updated)
+	}
+	if rbt.Len() != len(items) {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), len(items))
+	}
+
+	after := rbt.SliceAscend()
+	for i := range before {
+		if before[i] != after[i] {
+			t.Errorf("node %d has been changed by Replace", i)
+		}
+	}
+
+	old, replaced = rbt.Replace(&testStruct{5, "new"})
+	if replaced || old != nil {
+		t.Errorf("Replace() = %v, %v, expect nil, false", old, replaced)
+	}
+	if rbt.Len() != len(items)+1 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), len(items)+1)
+	}
+}
+
+func TestAscendContext(t *testing.T) {
+	rbt := New()
+	for i := 0; i < 10000; i++ {
+		rbt.Insert(Int(i))
+	}
+
+	n := 0
+	err := rbt.AscendContext(context.Background(), Int(0), func(i Item) bool {
+		n++
+		return true
+	})
+	if err != nil || n != 10000 {
+		t.Errorf("AscendContext() = %v after %d items, expect nil after %d", err, n, 10000)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n = 0
+	err = rbt.AscendContext(ctx, Int(0), func(i Item) bool {
+		n++
+		if n == 100 {
+			cancel()
+		}
+		return true
+	})
+	if err != context.Canceled {
+		t.Errorf("expected %v but got %v", context.Canceled, err)
+	}
+	if n < 100 || n > 100+contextCheckInterval {
+		t.Errorf("the iteration stopped after %d items, expect shortly after 100", n)
+	}
+
+	n = 0
+	err = rbt.AscendContext(ctx, Int(0), func(i Item) bool {
+		n++
+		return true
+	})
+	if err != context.Canceled || n != 0 {
+		t.Errorf("AscendContext() = %v after %d items, expect %v after 0", err, n, context.Canceled)
+	}
+}
+
+func TestAscendSafe(t *testing.T) {
+	rbt := New()
+	for i := 0; i < 100; i++ {
+		rbt.Insert(Int(i))
+	}
+
+	n := 0
+	err := rbt.AscendSafe(Int(10), func(i Item) bool {
+		n++
+		return true
+	})
+	if err != nil || n != 90 {
+		t.Errorf("AscendSafe() = %v after %d items, expect nil after %d", err, n, 90)
+	}
+
+	n = 0
+	err = rbt.AscendSafe(Int(10), func(i Item) bool {
+		n++
+		if i == Int(50) {
+			panic("bad item")
+		}
+		return true
+	})
+	if err == nil || !strings.Contains(err.Error(), "bad item") {
+		t.Errorf("AscendSafe() = %v, expect the panic", err)
+	}
+	if n != 41 {
+		t.Errorf("the iteration stopped after %d items, expect %d", n, 41)
+	}
+
+	errBad := errors.New("bad item")
+	err = rbt.AscendSafe(Int(0), func(i Item) bool {
+		panic(errBad)
+	})
+	if !errors.Is(err, errBad) {
+		t.Errorf("AscendSafe() = %v, expect it to wrap %v", err, errBad)
+	}
+
+	// The tree is still fine after the panics.
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	rbt.Delete(Int(50))
+	rbt.Insert(Int(100))
+	if rbt.Len() != 100 || rbt.Contains(Int(50)) || !rbt.Contains(Int(100)) {
+		t.Errorf("tree.Len() = %d, expect %d without 50", rbt.Len(), 100)
+	}
+}
+
+func TestItems(t *testing.T) {
+	rbt := New()
+	for _, v := range rand.Perm(1000) {
+		rbt.Insert(Int(v))
+	}
+
+	var ret []Item
+	for i := range rbt.Items() {
+		ret = append(ret, i)
+	}
+	if expected := rbt.appendItems(nil, rbt.root); !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+
+	for range New().Items() {
+		t.Errorf("unexpected item in empty tree")
+	}
+}
+
+func TestItemsContext(t *testing.T) {
+	rbt := New()
+	for i := 0; i < 1000; i++ {
+		rbt.Insert(Int(i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := rbt.ItemsContext(ctx)
+	for i := 0; i < 10; i++ {
+		if item := <-ch; item != Int(i) {
+			t.Errorf("expected %v but got %v", i, item)
+		}
+	}
+	cancel()
+
+	// The channel is closed by the goroutine once it exits, the items which
+	// were already buffered may still be received.
+	timeout := time.After(time.Second * 5)
+	n := 0
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				if n >= 1000-10 {
+					t.Errorf("all the %d items are received after cancel", n)
+				}
+				return
+			}
+			n++
+		case <-timeout:
+			t.Fatalf("the goroutine did not exit after cancel")
+		}
+	}
+}
+
+// ascend is the recursive counterpart of Ascend, which is compared with it
+// by the tests and the benchmarks.
+func (t *Rbtree) ascend(x *Node, pivot Item, iterator Iterator) bool {
+	if x == t.NIL {
+		return true
+	}
+
+	if !t.less(x.Item, pivot) {
+		if !t.ascend(x.left, pivot, iterator) {
+			return false
+		}
+		if !iterator(x.Item) {
+			return false
+		}
+	}
+
+	return t.ascend(x.right, pivot, iterator)
+}
+
+func TestAscendStop(t *testing.T) {
+	rbt := New()
+	for _, v := range rand.Perm(100) {
+		rbt.Insert(Int(v))
+	}
+
+	for _, pivot := range []Int{-10, 0, 33, 99, 100} {
+		var ret, expected []Item
+		rbt.Ascend(pivot, func(i Item) bool {
+			ret = append(ret, i)
+			return len(ret) < 20
+		})
+		rbt.ascend(rbt.root, pivot, func(i Item) bool {
+			expected = append(expected, i)
+			return len(expected) < 20
+		})
+		if !reflect.DeepEqual(ret, expected) {
+			t.Errorf("Ascend(%v): expected %v but got %v", pivot, expected, ret)
+		}
+	}
+}
+
+// benchmarkTree is shared by the benchmarks since it is expensive to build.
+var benchmarkTree *Rbtree
+
+func getBenchmarkTree(b *testing.B) *Rbtree {
+	if benchmarkTree == nil {
+		items := make([]Item, 1000000)
+		for i := range items {
+			items[i] = Int(i)
+		}
+		benchmarkTree = New()
+		if err := benchmarkTree.BulkInsertSorted(items); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return benchmarkTree
+}
+
+func BenchmarkAscend(b *testing.B) {
+	rbt := getBenchmarkTree(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rbt.Ascend(Int(0), func(Item) bool { return true })
+	}
+}
+
+func BenchmarkAscendRecursive(b *testing.B) {
+	rbt := getBenchmarkTree(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rbt.ascend(rbt.root, Int(0), func(Item) bool { return true })
+	}
+}
+
+func TestNodeValueAndColor(t *testing.T) {
+	rbt := New()
+	for i := 0; i < 10; i++ {
+		rbt.Insert(Int(i))
+	}
+
+	for i, n := range rbt.SliceAscend() {
+		if n.Value() != Int(i) {
+			t.Errorf("node %d has value %v, expect %v", i, n.Value(), i)
+		}
+		if c := n.Color(); c != "red" && c != "black" {
+			t.Errorf("node %d has color %q", i, c)
+		}
+	}
+
+	if c := rbt.root.Color(); c != "black" {
+		t.Errorf("root has color %q, expect black", c)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import "sync"
+
+// SafeRbtree wraps a Rbtree with a RWMutex so that it can be shared by
+// goroutines, any number of readers may run at the same time as long as
+// there is no writer.
+type SafeRbtree struct {
+	mu   sync.RWMutex
+	tree *Rbtree
+}
+
+// NewSafe returns a SafeRbtree guarding t, which should not be used directly
+// afterwards.
+func NewSafe(t *Rbtree) *SafeRbtree {
+	return &SafeRbtree{tree: t}
+}
+
+// Len returns number of nodes in the tree.
+func (s *SafeRbtree) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.Len()
+}
+
+// Insert inserts the item into the tree, see Rbtree.Insert.
+func (s *SafeRbtree) Insert(item Item) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.Insert(item)
+}
+
+// Delete deletes the item from the tree, see Rbtree.Delete.
+func (s *SafeRbtree) Delete(item Item) Item {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.Delete(item)
+}
+
+// Get searches for the item equal to key, see Rbtree.Get.
+func (s *SafeRbtree) Get(key Item) (Item, bool) {
+	// Get records the access in a tree made by NewWithLRU, which writes.
+	if s.tree.lru != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	} else {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+	return s.tree.Get(key)
+}
+
+// Ascend is the same as Rbtree.Ascend, the read lock is held during the
+// whole traversal so that the iterator sees a consistent tree. The iterator
+// must not modify the tree, otherwise it will deadlock.
+func (s *SafeRbtree) Ascend(pivot Item, iterator Iterator) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.tree.Ascend(pivot, iterator)
+}
+
+// Snapshot copies the items in ascending order into a slice under the read
+// lock and returns it, so that the caller can go through them as slowly as
+// it likes without blocking the writers. The items themselves are shared,
+// but the slice costs one interface value, i.e. two words, per item.
+func (s *SafeRbtree) Snapshot() []Item {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.Collect()
+}
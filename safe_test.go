@@ -0,0 +1,93 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSafeRbtree(t *testing.T) {
+	s := NewSafe(New())
+
+	for i := 0; i < 100; i++ {
+		s.Insert(Int(i))
+	}
+
+	var wg sync.WaitGroup
+
+	// The writer keeps inserting and deleting the items above 100 while the
+	// readers go through the tree, which must always look ordered to them.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 100; i < 1000; i++ {
+			s.Insert(Int(i))
+			if i%2 == 0 {
+				s.Delete(Int(i))
+			}
+		}
+	}()
+
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 50; n++ {
+				prev := Int(-1)
+				s.Ascend(Int(0), func(i Item) bool {
+					if i.(Int) <= prev {
+						t.Errorf("%v is visited after %v", i, prev)
+						return false
+					}
+					prev = i.(Int)
+					return true
+				})
+				if _, ok := s.Get(Int(n)); !ok {
+					t.Errorf("%d is expect exists", n)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if s.Len() != 100+450 {
+		t.Errorf("tree.Len() = %d, expect %d", s.Len(), 100+450)
+	}
+}
+
+func TestSafeRbtreeSnapshot(t *testing.T) {
+	s := NewSafe(New())
+	for i := 0; i < 100; i++ {
+		s.Insert(Int(i))
+	}
+
+	snapshot := s.Snapshot()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			s.Delete(Int(i))
+			s.Insert(Int(i + 100))
+		}
+	}()
+
+	// The writer does not affect the snapshot, nor is it blocked by it.
+	for i, item := range snapshot {
+		if item != Int(i) {
+			t.Errorf("item %d is %v, expect %v", i, item, Int(i))
+		}
+	}
+	<-done
+
+	if len(snapshot) != 100 {
+		t.Errorf("len(Snapshot()) = %d, expect %d", len(snapshot), 100)
+	}
+	if ret := s.Snapshot(); ret[0] != Int(100) || len(ret) != 100 {
+		t.Errorf("Snapshot() = %v, expect 100 to 199", ret)
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import "errors"
+
+// ErrOverlap is returned by Join if the items of the trees are not
+// separated.
+var ErrOverlap = errors.New("rbtree: the items of the trees overlap")
+
+// Equal returns whether t and other have the same items, i.e. the items at
+// the same position in ascending order are equal. The shapes of the trees
+// do not matter.
+func (t *Rbtree) Equal(other *Rbtree) bool {
+	if t.count != other.count {
+		return false
+	}
+
+	x, y := t.min(t.root), other.min(other.root)
+	for x != t.NIL && y != other.NIL {
+		if t.less(x.Item, y.Item) || t.less(y.Item, x.Item) {
+			return false
+		}
+		x, y = t.successor(x), other.successor(y)
+	}
+	return true
+}
+
+// Diff compares t with other, added are the items which are in other but not
+// in t, and removed are the ones in t but not in other, both in ascending
+// order.
+func (t *Rbtree) Diff(other *Rbtree) (added, removed []Item) {
+	x, y := t.min(t.root), other.min(other.root)
+	for x != t.NIL || y != other.NIL {
+		if y == other.NIL || (x != t.NIL && t.less(x.Item, y.Item)) {
+			removed = append(removed, x.Item)
+			x = t.successor(x)
+		} else if x == t.NIL || t.less(y.Item, x.Item) {
+			added = append(added, y.Item)
+			y = other.successor(y)
+		} else {
+			x, y = t.successor(x), other.successor(y)
+		}
+	}
+	return added, removed
+}
+
+// Union returns a new tree of the items which are in either t or other, the
+// item of t is kept if both have an equal one. The result orders the items
+// the same way as t.
+func (t *Rbtree) Union(other *Rbtree) *Rbtree {
+	a := t.appendItems(make([]Item, 0, t.count), t.root)
+	b := other.appendItems(make([]Item, 0, other.count), other.root)
+
+	c := t.emptyClone()
+	c.build(t.unionItems(a, b))
+	return c
+}
+
+// Intersect returns a new tree of the items which are in both t and other,
+// the ones of t are kept. The result orders the items the same way as t.
+func (t *Rbtree) Intersect(other *Rbtree) *Rbtree {
+	var result []Item
+
+	// Sweep both trees in ascending order at the same time.
+	x, y := t.min(t.root), other.min(other.root)
+	for x != t.NIL && y != other.NIL {
+		if t.less(x.Item, y.Item) {
+			x = t.successor(x)
+		} else if t.less(y.Item, x.Item) {
+			y = other.successor(y)
+		} else {
+			result = append(result, x.Item)
+			x, y = t.successor(x), other.successor(y)
+		}
+	}
+
+	c := t.emptyClone()
+	c.build(result)
+	return c
+}
+
+// Difference returns a new tree of the items which are in t but not in
+// other. The result orders the items the same way as t.
+func (t *Rbtree) Difference(other *Rbtree) *Rbtree {
+	var result []Item
+
+	x, y := t.min(t.root), other.min(other.root)
+	for x != t.NIL {
+		if y == other.NIL || t.less(x.Item, y.Item) {
+			result = append(result, x.Item)
+			x = t.successor(x)
+		} else if t.less(y.Item, x.Item) {
+			y = other.successor(y)
+		} else {
+			x, y = t.successor(x), other.successor(y)
+		}
+	}
+
+	c := t.emptyClone()
+	c.build(result)
+	return c
+}
+
+// Filter returns a new tree of the items of t for which pred returns true.
+// The items are already in order, so the result is built in O(n) and orders
+// them the same way as t.
+func (t *Rbtree) Filter(pred func(Item) bool) *Rbtree {
+	var result []Item
+
+	t.walk(t.root, func(i Item) bool {
+		if pred(i) {
+			result = append(result, i)
+		}
+		return true
+	})
+
+	c := t.emptyClone()
+	c.build(result)
+	return c
+}
+
+// Map returns a new tree of the results of fn for all the items of t, which
+// are inserted one by one since fn may change their order. Hence the result
+// orders them the same way as t but the order of the items of t is not
+// preserved. Equal results are handled the same as by Insert and nil ones
+// are dropped.
+func (t *Rbtree) Map(fn func(Item) Item) *Rbtree {
+	c := t.emptyClone()
+
+	t.walk(t.root, func(i Item) bool {
+		c.Insert(fn(i))
+		return true
+	})
+
+	return c
+}
+
+// Split moves the items of t into two new trees, left gets the ones less
+// than pivot and right gets the others. Both of them are built in O(n) and
+// t is left empty.
+func (t *Rbtree) Split(pivot Item) (left, right *Rbtree) {
+	items := t.appendItems(make([]Item, 0, t.count), t.root)
+	k := len(items)
+	if pivot != nil {
+		k, _ = t.Rank(pivot)
+	}
+
+	left, right = t.emptyClone(), t.emptyClone()
+	left.build(items[:k])
+	right.build(items[k:])
+	left.verify("Split", pivot)
+	right.verify("Split", pivot)
+
+	t.Clear()
+	return left, right
+}
+
+// Join moves all the items of other, which must be greater than all the
+// items of t, into t and leaves other empty. Both trees are zipped along the
+// spines in O(log n) rather than inserting the items one by one, except that
+// the leaves of the smaller tree have to be redirected to the NIL of the
+// larger one, which takes O(m) for the smaller size m.
+func (t *Rbtree) Join(other *Rbtree) error {
+	if other.count == 0 {
+		return nil
+	}
+	if t.count == 0 {
+		t.adoptAccesses(other)
+		t.NIL, t.root, t.count = other.NIL, other.root, other.count
+		other.reset()
+		t.verify("Join", nil)
+		return nil
+	}
+	if !t.less(t.max(t.root).Item, other.min(other.root).Item) {
+		return ErrOverlap
+	}
+
+	// The minimum of other joins both trees together.
+	k, _ := other.PopMin()
+	if other.count == 0 {
+		t.insert(k, true)
+		t.verify("Join", nil)
+		return nil
+	}
+
+	t.adoptAccesses(other)
+	if t.count < other.count {
+		t.relink(t.root, other.NIL)
+		t.NIL = other.NIL
+	} else {
+		other.relink(other.root, t.NIL)
+	}
+
+	a, b := t.root, other.root
+	bha, bhb := t.blackHeight(a), t.blackHeight(b)
+	z := t.newNode(k)
+
+	if bha >= bhb {
+		// Find the black node on the right spine of a which has the same
+		// black height as b, and replace it by z whose children are that
+		// node and b.
+		y, h := a, bha
+		for y.color != BLACK || h != bhb {
+			if y.color == BLACK {
+				h--
+			}
+			y = y.right
+		}
+
+		z.parent = y.parent
+		if y == a {
+			t.root = z
+		} else {
+			y.parent.right = z
+		}
+		z.left, z.right = y, b
+	} else {
+		// The same on the left spine of b.
+		y, h := b, bhb
+		for y.color != BLACK || h != bha {
+			if y.color == BLACK {
+				h--
+			}
+			y = y.left
+		}
+
+		t.root = b
+		z.parent = y.parent
+		if y == b {
+			t.root = z
+		} else {
+			y.parent.left = z
+		}
+		z.left, z.right = a, y
+	}
+
+	z.left.parent, z.right.parent = z, z
+	for p := z; p != t.NIL; p = p.parent {
+		p.size = p.left.size + p.right.size + 1
+	}
+	t.reweigh(z)
+
+	t.count += other.count + 1
+	t.insertFixup(z)
+
+	other.reset()
+	t.verify("Join", nil)
+	return nil
+}
+
+// relink makes the subtree rooted at x refer to sentinel instead of t.NIL.
+func (t *Rbtree) relink(x, sentinel *Node) {
+	if x.parent == t.NIL {
+		x.parent = sentinel
+	}
+	if x.left == t.NIL {
+		x.left = sentinel
+	} else {
+		t.relink(x.left, sentinel)
+	}
+	if x.right == t.NIL {
+		x.right = sentinel
+	} else {
+		t.relink(x.right, sentinel)
+	}
+}
+
+// blackHeight returns the number of black nodes on the path from x to any
+// leaf.
+func (t *Rbtree) blackHeight(x *Node) int {
+	bh := 0
+	for ; x != t.NIL; x = x.left {
+		if x.color == BLACK {
+			bh++
+		}
+	}
+	return bh
+}
+
+// unionItems merges two slices of items in strictly ascending order into a
+// new one, the item of a is kept if both have an equal one.
+func (t *Rbtree) unionItems(a, b []Item) []Item {
+	result := make([]Item, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if t.less(a[0], b[0]) {
+			result = append(result, a[0])
+			a = a[1:]
+		} else if t.less(b[0], a[0]) {
+			result = append(result, b[0])
+			b = b[1:]
+		} else {
+			result = append(result, a[0])
+			a, b = a[1:], b[1:]
+		}
+	}
+
+	result = append(result, a...)
+	return append(result, b...)
+}
+
+// mergeItems merges two slices of items in ascending order into a new one
+// and keeps all of them, the items of a go first among equal ones.
+func (t *Rbtree) mergeItems(a, b []Item) []Item {
+	result := make([]Item, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if t.less(b[0], a[0]) {
+			result = append(result, b[0])
+			b = b[1:]
+		} else {
+			result = append(result, a[0])
+			a = a[1:]
+		}
+	}
+
+	result = append(result, a...)
+	return append(result, b...)
+}
@@ -0,0 +1,325 @@
+// Copyright 2015, Hu Keping. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rbtree
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// newIntTree returns a tree of Int(v) for each v.
+func newIntTree(values ...int) *Rbtree {
+	rbt := New()
+	for _, v := range values {
+		rbt.Insert(Int(v))
+	}
+	return rbt
+}
+
+// intRange returns the integers in [from, to) with the step.
+func intRange(from, to, step int) []int {
+	var values []int
+	for i := from; i < to; i += step {
+		values = append(values, i)
+	}
+	return values
+}
+
+// items returns the items of the tree in ascending order.
+func items(rbt *Rbtree) []Item {
+	var ret []Item
+	for _, n := range rbt.SliceAscend() {
+		ret = append(ret, n.Item)
+	}
+	return ret
+}
+
+func TestUnion(t *testing.T) {
+	tests := []struct {
+		a, b, expected []int
+	}{
+		{intRange(0, 10, 1), intRange(5, 15, 1), intRange(0, 15, 1)},
+		{intRange(0, 10, 2), intRange(1, 10, 2), intRange(0, 10, 1)},
+		{intRange(0, 5, 1), intRange(10, 15, 1), append(intRange(0, 5, 1), intRange(10, 15, 1)...)},
+		{nil, intRange(0, 5, 1), intRange(0, 5, 1)},
+		{nil, nil, nil},
+	}
+
+	for _, test := range tests {
+		a, b := newIntTree(test.a...), newIntTree(test.b...)
+		u := a.Union(b)
+		if err := u.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+		if u.Len() != len(test.expected) {
+			t.Errorf("%v | %v: Len() = %d, expect %d", test.a, test.b, u.Len(), len(test.expected))
+		}
+		if expected := items(newIntTree(test.expected...)); !reflect.DeepEqual(items(u), expected) {
+			t.Errorf("%v | %v: expected %v but got %v", test.a, test.b, expected, items(u))
+		}
+		if a.Len() != len(test.a) || b.Len() != len(test.b) {
+			t.Errorf("the operands have been changed")
+		}
+	}
+
+	// The items of the receiver win.
+	a, b := New(), New()
+	a.Insert(&testStruct{1, "a"})
+	b.Insert(&testStruct{1, "b"})
+	b.Insert(&testStruct{2, "b"})
+	u := a.Union(b)
+	if item, _ := u.Get(&testStruct{1, ""}); item.(*testStruct).text != "a" {
+		t.Errorf("expected the item of the receiver but got %v", item)
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	tests := []struct {
+		a, b, expected []int
+	}{
+		{intRange(0, 10, 1), intRange(20, 30, 1), nil},
+		{intRange(0, 10, 1), intRange(0, 10, 1), intRange(0, 10, 1)},
+		{intRange(0, 30, 2), intRange(0, 30, 3), intRange(0, 30, 6)},
+		{intRange(0, 10, 1), intRange(5, 15, 1), intRange(5, 10, 1)},
+		{nil, intRange(0, 5, 1), nil},
+	}
+
+	for _, test := range tests {
+		a, b := newIntTree(test.a...), newIntTree(test.b...)
+		c := a.Intersect(b)
+		if err := c.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+		if c.Len() != len(test.expected) {
+			t.Errorf("%v & %v: Len() = %d, expect %d", test.a, test.b, c.Len(), len(test.expected))
+		}
+		if expected := items(newIntTree(test.expected...)); !reflect.DeepEqual(items(c), expected) {
+			t.Errorf("%v & %v: expected %v but got %v", test.a, test.b, expected, items(c))
+		}
+	}
+}
+
+func TestDifference(t *testing.T) {
+	tests := []struct {
+		a, b, expected []int
+	}{
+		{intRange(0, 10, 1), intRange(0, 10, 1), nil},
+		{intRange(0, 10, 1), nil, intRange(0, 10, 1)},
+		{nil, intRange(0, 10, 1), nil},
+		{intRange(0, 10, 1), intRange(5, 15, 1), intRange(0, 5, 1)},
+		{intRange(0, 20, 1), intRange(1, 20, 2), intRange(0, 20, 2)},
+		{intRange(0, 10, 1), intRange(20, 30, 1), intRange(0, 10, 1)},
+	}
+
+	for _, test := range tests {
+		a, b := newIntTree(test.a...), newIntTree(test.b...)
+		c := a.Difference(b)
+		if err := c.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+		if c.Len() != len(test.expected) {
+			t.Errorf("%v - %v: Len() = %d, expect %d", test.a, test.b, c.Len(), len(test.expected))
+		}
+		if expected := items(newIntTree(test.expected...)); !reflect.DeepEqual(items(c), expected) {
+			t.Errorf("%v - %v: expected %v but got %v", test.a, test.b, expected, items(c))
+		}
+	}
+
+	a := newIntTree(intRange(0, 10, 1)...)
+	if c := a.Difference(a); c.Len() != 0 {
+		t.Errorf("a.Difference(a).Len() = %d, expect 0", c.Len())
+	}
+}
+
+func TestFilter(t *testing.T) {
+	a := newIntTree(intRange(1, 101, 1)...)
+	c := a.Filter(func(i Item) bool {
+		return i.(Int)%2 == 0
+	})
+	if err := c.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 50 {
+		t.Errorf("Filter().Len() = %d, expect %d", c.Len(), 50)
+	}
+	if expected := items(newIntTree(intRange(2, 101, 2)...)); !reflect.DeepEqual(items(c), expected) {
+		t.Errorf("expected %v but got %v", expected, items(c))
+	}
+	if a.Len() != 100 {
+		t.Errorf("tree.Len() = %d, expect %d", a.Len(), 100)
+	}
+
+	if c := a.Filter(func(Item) bool { return false }); c.Len() != 0 {
+		t.Errorf("Filter().Len() = %d, expect 0", c.Len())
+	}
+}
+
+func TestMap(t *testing.T) {
+	a := newIntTree(intRange(0, 10, 1)...)
+	c := a.Map(func(i Item) Item {
+		return -i.(Int)
+	})
+	if err := c.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := items(newIntTree(intRange(-9, 1, 1)...)); !reflect.DeepEqual(items(c), expected) {
+		t.Errorf("expected %v but got %v", expected, items(c))
+	}
+
+	// Equal results are merged.
+	c = a.Map(func(i Item) Item {
+		return i.(Int) / 2
+	})
+	if expected := items(newIntTree(intRange(0, 5, 1)...)); !reflect.DeepEqual(items(c), expected) {
+		t.Errorf("expected %v but got %v", expected, items(c))
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		values      []int
+		pivot       int
+		left, right []int
+	}{
+		{intRange(0, 100, 1), 50, intRange(0, 50, 1), intRange(50, 100, 1)},
+		{intRange(0, 100, 2), 51, intRange(0, 52, 2), intRange(52, 100, 2)},
+		{intRange(0, 10, 1), -1, nil, intRange(0, 10, 1)},
+		{intRange(0, 10, 1), 10, intRange(0, 10, 1), nil},
+		{nil, 1, nil, nil},
+	}
+
+	for _, test := range tests {
+		rbt := newIntTree(test.values...)
+		left, right := rbt.Split(Int(test.pivot))
+
+		for _, half := range []*Rbtree{left, right} {
+			if err := half.CheckInvariants(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if expected := items(newIntTree(test.left...)); !reflect.DeepEqual(items(left), expected) {
+			t.Errorf("left of %d: expected %v but got %v", test.pivot, expected, items(left))
+		}
+		if expected := items(newIntTree(test.right...)); !reflect.DeepEqual(items(right), expected) {
+			t.Errorf("right of %d: expected %v but got %v", test.pivot, expected, items(right))
+		}
+		if left.Len()+right.Len() != len(test.values) {
+			t.Errorf("the halves have %d items, expect %d", left.Len()+right.Len(), len(test.values))
+		}
+		if !rbt.IsEmpty() {
+			t.Errorf("the original tree is expect to be empty")
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	tests := []struct {
+		a, b []int
+	}{
+		{intRange(1, 51, 1), intRange(51, 101, 1)},
+		{intRange(0, 1000, 1), intRange(1000, 1003, 1)},
+		{intRange(0, 3, 1), intRange(3, 1000, 1)},
+		{intRange(0, 10, 1), []int{10}},
+		{nil, intRange(0, 10, 1)},
+		{intRange(0, 10, 1), nil},
+		{nil, nil},
+	}
+
+	for _, test := range tests {
+		a, b := newIntTree(test.a...), newIntTree(test.b...)
+		if err := a.Join(b); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.CheckInvariants(); err != nil {
+			t.Fatalf("%d + %d items: %v", len(test.a), len(test.b), err)
+		}
+		if !b.IsEmpty() || b.CheckInvariants() != nil {
+			t.Errorf("the other tree is expect to be empty")
+		}
+
+		expected := items(newIntTree(append(test.a, test.b...)...))
+		if !reflect.DeepEqual(items(a), expected) {
+			t.Errorf("expected %v but got %v", expected, items(a))
+		}
+
+		// Both trees remain usable afterwards.
+		a.Insert(Int(-1))
+		a.Delete(Int(5))
+		b.Insert(Int(1))
+		if err := a.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestJoinOverlap(t *testing.T) {
+	a, b := newIntTree(intRange(0, 10, 1)...), newIntTree(intRange(9, 20, 1)...)
+	if err := a.Join(b); err != ErrOverlap {
+		t.Errorf("expected %v but got %v", ErrOverlap, err)
+	}
+	if a.Len() != 10 || b.Len() != 11 {
+		t.Errorf("the trees have been changed")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	values := intRange(0, 100, 1)
+	a := newIntTree(values...)
+
+	shuffled := rand.Perm(100)
+	b := newIntTree(shuffled...)
+	if !a.Equal(b) || !b.Equal(a) {
+		t.Errorf("trees of the same items are expect to be equal")
+	}
+
+	c := New()
+	if err := c.BulkInsertSorted(items(a)); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Equal(c) {
+		t.Errorf("trees of the same items are expect to be equal")
+	}
+
+	b.Delete(Int(50))
+	if a.Equal(b) || b.Equal(a) {
+		t.Errorf("trees differing by one item are expect not to be equal")
+	}
+	b.Insert(Int(100))
+	if a.Equal(b) || b.Equal(a) {
+		t.Errorf("trees differing by one item are expect not to be equal")
+	}
+
+	if !New().Equal(New()) {
+		t.Errorf("empty trees are expect to be equal")
+	}
+	if a.Equal(New()) {
+		t.Errorf("a tree is expect not to equal the empty tree")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		a, b           []int
+		added, removed []int
+	}{
+		{intRange(0, 10, 1), intRange(0, 10, 1), nil, nil},
+		{nil, nil, nil, nil},
+		{intRange(0, 5, 1), intRange(5, 10, 1), intRange(5, 10, 1), intRange(0, 5, 1)},
+		{intRange(0, 10, 1), append(intRange(0, 10, 1), 10), []int{10}, nil},
+		{intRange(0, 10, 1), intRange(1, 10, 1), nil, []int{0}},
+		{intRange(0, 20, 2), intRange(0, 20, 3), []int{3, 9, 15}, []int{2, 4, 8, 10, 14, 16}},
+	}
+
+	for _, test := range tests {
+		added, removed := newIntTree(test.a...).Diff(newIntTree(test.b...))
+		if expected := items(newIntTree(test.added...)); !reflect.DeepEqual(added, expected) {
+			t.Errorf("%v -> %v: expected %v added but got %v", test.a, test.b, expected, added)
+		}
+		if expected := items(newIntTree(test.removed...)); !reflect.DeepEqual(removed, expected) {
+			t.Errorf("%v -> %v: expected %v removed but got %v", test.a, test.b, expected, removed)
+		}
+	}
+}
@@ -4,21 +4,156 @@
 
 package rbtree
 
+import (
+	"errors"
+	"sort"
+)
+
 // This file contains most of the methods that can be used
 // by the user. Anyone who wants to look for some API about
 // the rbtree, this is the right place.
 
+// ErrNotSorted is returned by BulkInsertSorted if the items are not in
+// strictly ascending order.
+var ErrNotSorted = errors.New("rbtree: items are not in strictly ascending order")
+
 // Len returns number of nodes in the tree.
-func (t *Rbtree) Len() uint { return t.count }
+func (t *Rbtree) Len() int { return t.count }
+
+// IsEmpty returns whether there is no node in the tree.
+func (t *Rbtree) IsEmpty() bool { return t.count == 0 }
+
+// Clear removes all the items from the tree. The whole tree is simply
+// dropped and left to the garbage collector, unless the tree has a pool
+// which takes all the nodes back. The tree itself can be reused afterwards.
+func (t *Rbtree) Clear() {
+	if t.pool != nil {
+		t.freeTree(t.root)
+	}
+
+	t.root = t.NIL
+	t.count = 0
+	if t.lru != nil {
+		t.lru.reset()
+	}
+}
+
+// Clone returns a copy of the tree which has exactly the same shape and
+// colors, the items themselves are shared rather than copied.
+func (t *Rbtree) Clone() *Rbtree {
+	c := t.emptyClone()
+	c.root = t.clone(c, t.root, c.NIL)
+	c.count = t.count
+	return c
+}
+
+// Rebuild replaces all the nodes by a perfectly balanced tree of the same
+// items in O(n), which has the least possible height. The tree stays valid
+// after any number of deletions anyway, but it may be deeper than needed.
+func (t *Rbtree) Rebuild() {
+	items := t.Collect()
+	t.Clear()
+	t.build(items)
+	t.verify("Rebuild", nil)
+}
 
-// Insert func inserts a item as a new RED node
-func (t *Rbtree) Insert(item Item) {
+// Insert func inserts a item as a new RED node and returns true. If there is
+// already an equal item in the tree, it is replaced by the new one in place,
+// the count is unchanged and Insert returns false, unless the tree allows
+// duplicates, see NewWithDuplicates.
+//
+// A nil item cannot be compared with the others, so inserting it is a no-op
+// which returns false and leaves the tree unaffected. So is inserting an
+// item which is not greater than the minimum one into a full tree made by
+// NewBounded.
+func (t *Rbtree) Insert(item Item) bool {
 	if item == nil {
-		return
+		return false
+	}
+
+	full := t.bound > 0 && t.count >= t.bound
+	if full && !t.less(t.min(t.root).Item, item) {
+		return false
 	}
 
 	// Always insert a RED node
-	t.insert(&Node{t.NIL, t.NIL, t.NIL, RED, item})
+	x, inserted := t.insert(item, true)
+	if !inserted {
+		x.Item = item
+		t.reweigh(x)
+	} else if full {
+		t.deleteNode(t.min(t.root))
+	}
+	t.verify("Insert", item)
+	return inserted
+}
+
+// InsertMany inserts the items one by one, the same as Insert, and returns
+// the number of them which have been newly added.
+func (t *Rbtree) InsertMany(items ...Item) int {
+	n := 0
+	for _, item := range items {
+		if t.Insert(item) {
+			n++
+		}
+	}
+	return n
+}
+
+// BulkInsertSorted inserts the items, which must be in strictly ascending
+// order, in O(n) by building a balanced tree of them directly rather than
+// inserting them one by one. If there are already items in the tree, both
+// are merged and the new ones replace the equal ones in the tree, the same as
+// Insert does. If the tree allows duplicates, equal items are accepted and
+// all of them are kept, the new ones after the ones in the tree.
+// Nothing is inserted if the items are out of order or there is a nil one.
+func (t *Rbtree) BulkInsertSorted(items []Item) error {
+	for i, item := range items {
+		if item == nil || (i > 0 && t.less(item, items[i-1])) {
+			return ErrNotSorted
+		}
+		if i > 0 && !t.dup && !t.less(items[i-1], item) {
+			return ErrNotSorted
+		}
+	}
+
+	t.bulkInsert(items)
+	t.verify("BulkInsertSorted", nil)
+	return nil
+}
+
+// BulkInsertSortedUnique is the same as BulkInsertSorted except that the
+// items only have to be in ascending order, each run of equal items is
+// collapsed into the last one of them, the same as inserting them one by
+// one would do, before the items are inserted. This suits the sorted sources
+// which hold duplicates.
+func (t *Rbtree) BulkInsertSortedUnique(items []Item) error {
+	unique := make([]Item, 0, len(items))
+	for i, item := range items {
+		if item == nil || (i > 0 && t.less(item, items[i-1])) {
+			return ErrNotSorted
+		}
+		if k := len(unique); k > 0 && !t.less(unique[k-1], item) {
+			unique[k-1] = item
+		} else {
+			unique = append(unique, item)
+		}
+	}
+
+	t.bulkInsert(unique)
+	t.verify("BulkInsertSortedUnique", nil)
+	return nil
+}
+
+// bulkInsert merges the items, which are in order, with the ones in the tree
+// and builds a new tree of all of them.
+func (t *Rbtree) bulkInsert(items []Item) {
+	if t.count > 0 && t.dup {
+		items = t.mergeItems(t.appendItems(make([]Item, 0, t.count), t.root), items)
+	} else if t.count > 0 {
+		items = t.unionItems(items, t.appendItems(make([]Item, 0, t.count), t.root))
+	}
+	t.build(items)
 }
 
 //InsertOrGet inserts or retrieves the item in the tree. If the
@@ -30,59 +165,576 @@ func (t *Rbtree) InsertOrGet(item Item) Item {
 		return nil
 	}
 
-	return t.insert(&Node{t.NIL, t.NIL, t.NIL, RED, item}).Item
+	x, _ := t.insert(item, false)
+	t.verify("InsertOrGet", item)
+	return x.Item
+}
+
+// GetOrInsert returns the item in the tree which is equal to the specified
+// one and true if there is such an item, otherwise it inserts the specified
+// item and returns it with false. Both are done in a single pass.
+func (t *Rbtree) GetOrInsert(item Item) (Item, bool) {
+	if item == nil {
+		return nil, false
+	}
+
+	x, inserted := t.insert(item, false)
+	t.verify("GetOrInsert", item)
+	return x.Item, !inserted
+}
+
+// Replace stores the item in the place of the first equal one in the tree and
+// returns the old item with true. The node is reused so that neither the
+// shape of the tree nor the count changes. If there is no equal item, the
+// item is inserted and Replace returns false.
+//
+// If the tree has an equal function set by SetEqual but does not allow
+// duplicates, the item at the same position is replaced anyway since there
+// is no room for another one.
+func (t *Rbtree) Replace(item Item) (Item, bool) {
+	if item == nil {
+		return nil, false
+	}
+
+	var x *Node
+	if t.equal != nil && t.dup {
+		if x = t.find(item); x == t.NIL {
+			t.insert(item, true)
+			t.verify("Replace", item)
+			return nil, false
+		}
+	} else {
+		var inserted bool
+		if x, inserted = t.insert(item, false); inserted {
+			t.verify("Replace", item)
+			return nil, false
+		}
+	}
+
+	old := x.Item
+	x.Item = item
+	t.reweigh(x)
+	t.verify("Replace", item)
+	return old, true
 }
 
-//Delete delete the item in the tree
+// Delete deletes the item equal to the specified one from the tree and
+// returns it, or nil if there is no such item. Insert never stores a nil
+// Item, so the nil return cannot be mistaken for a stored item, not even for
+// a nil pointer, which is a non-nil Item. The other lookups, e.g. Get, Min
+// or Floor, return an explicit bool anyway.
 func (t *Rbtree) Delete(item Item) Item {
 	if item == nil {
 		return nil
 	}
 
 	// The `color` field here is nobody
-	return t.delete(&Node{t.NIL, t.NIL, t.NIL, RED, item}).Item
+	ret := t.delete(&Node{t.NIL, t.NIL, t.NIL, RED, 0, 0, item})
+	t.verify("Delete", item)
+	return ret
 }
 
-//Get search for the specified items which is carried by a Node
-func (t *Rbtree) Get(item Item) Item {
+// InsertNode inserts the item the same way as InsertOrGet and returns the
+// node carrying it, or the node of the equal item already in the tree. It
+// returns nil if the item is nil.
+//
+// The nodes are relinked rather than moved around by the other insertions
+// and deletions, so the node keeps carrying the same item and can be given
+// to DeleteNode later on without searching for it again.
+func (t *Rbtree) InsertNode(item Item) *Node {
 	if item == nil {
 		return nil
 	}
 
-	// The `color` field here is nobody
-	ret := t.search(&Node{t.NIL, t.NIL, t.NIL, RED, item})
-	if ret == nil {
-		return nil
+	x, _ := t.insert(item, false)
+	t.verify("InsertNode", item)
+	return x
+}
+
+// DeleteNode deletes the node, e.g. returned by InsertNode or SliceAscend,
+// without searching for its item. It returns false if the node is nil, the
+// NIL sentinel, already deleted or in another tree, which is checked by
+// following the parent pointers up to the root.
+//
+// The node is not put back to the pool of a tree made by NewWithPool, since
+// the caller still holds it, so deleting it again keeps returning false.
+// Only the node of an item deleted otherwise, e.g. by Delete, is recycled,
+// and then it may be live again and carry another item.
+func (t *Rbtree) DeleteNode(n *Node) bool {
+	if n == nil || !t.owns(n) {
+		return false
+	}
+
+	item := t.unlinkNode(n)
+	t.verify("DeleteNode", item)
+	return true
+}
+
+// DeleteAll deletes all the items in the tree which are equal to the
+// specified one and returns the number of them. Unless the tree allows
+// duplicates, there is at most one of them.
+func (t *Rbtree) DeleteAll(item Item) int {
+	if item == nil {
+		return 0
+	}
+
+	n := 0
+	for {
+		z := t.search(&Node{t.NIL, t.NIL, t.NIL, RED, 0, 0, item})
+		if z == t.NIL {
+			return n
+		}
+		t.deleteNode(z)
+		t.verify("DeleteAll", item)
+		n++
+	}
+}
+
+// bulkDeleteRatio is the fraction of the tree, 1 / bulkDeleteRatio, from
+// which BulkDelete and RangeDelete rebuild the tree instead of deleting the
+// items one by one.
+const bulkDeleteRatio = 8
+
+// BulkDelete deletes one item equal to each of the specified ones, the
+// absent ones are skipped, and returns the number of items deleted.
+//
+// If there are many items compared to the size of the tree, they are sorted
+// and swept together with the tree, then the tree is rebuilt from the
+// remaining items in O(n) rather than rebalanced after every deletion.
+func (t *Rbtree) BulkDelete(items []Item) int {
+	// Rebuilding the tree would forget the order of the accesses.
+	if len(items) < t.count/bulkDeleteRatio || t.lru != nil {
+		n := 0
+		for _, item := range items {
+			if t.Delete(item) != nil {
+				n++
+			}
+		}
+		return n
+	}
+
+	keys := make([]Item, 0, len(items))
+	for _, item := range items {
+		if item != nil {
+			keys = append(keys, item)
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return t.less(keys[i], keys[j]) })
+
+	var result []Item
+	for x := t.min(t.root); x != t.NIL; {
+		if len(keys) == 0 || t.less(x.Item, keys[0]) {
+			result = append(result, x.Item)
+			x = t.successor(x)
+		} else if t.less(keys[0], x.Item) {
+			keys = keys[1:]
+		} else {
+			x, keys = t.successor(x), keys[1:]
+		}
+	}
+
+	n := t.count - len(result)
+	t.Clear()
+	t.build(result)
+	t.verify("BulkDelete", nil)
+	return n
+}
+
+// RangeDelete deletes all the items which are greater or equal than @lo and
+// less than @hi, i.e. in the range [lo, hi), and returns the number of them.
+// A large range is cut out of the items in order and the tree is rebuilt
+// from the rest, as BulkDelete does.
+func (t *Rbtree) RangeDelete(lo, hi Item) int {
+	n := t.CountRange(lo, hi)
+	if n == 0 {
+		return 0
+	}
+
+	if n < t.count/bulkDeleteRatio || t.lru != nil {
+		for i := 0; i < n; i++ {
+			t.deleteNode(t.ceiling(lo))
+			t.verify("RangeDelete", lo)
+		}
+		return n
+	}
+
+	inf, _ := t.Rank(lo)
+	items := t.appendItems(make([]Item, 0, t.count), t.root)
+	items = append(items[:inf], items[inf+n:]...)
+
+	t.Clear()
+	t.build(items)
+	t.verify("RangeDelete", lo)
+	return n
+}
+
+// Get searches for the item which is equal to the specified key, i.e.
+// neither of them is less than the other, and returns the one stored in the
+// tree rather than the key, the first one if the tree holds duplicates. It
+// returns false if there is no such item. If the tree has an equal function
+// set by SetEqual, the item must also be equal to the key by it.
+func (t *Rbtree) Get(key Item) (Item, bool) {
+	if key == nil {
+		return nil, false
 	}
 
-	return ret.Item
+	ret := t.find(key)
+	if ret == t.NIL {
+		return nil, false
+	}
+
+	t.touch(ret)
+	return ret.Item, true
+}
+
+// Contains returns whether there is an item in the tree which is equal to
+// the specified one, the same way as Get.
+func (t *Rbtree) Contains(item Item) bool {
+	if item == nil {
+		return false
+	}
+
+	return t.find(item) != t.NIL
 }
 
 // Search does only search the node which includes it node
 //TODO: This is for debug, delete it in the future
 func (t *Rbtree) Search(item Item) *Node {
 
-	return t.search(&Node{t.NIL, t.NIL, t.NIL, RED, item})
+	return t.search(&Node{t.NIL, t.NIL, t.NIL, RED, 0, 0, item})
+}
+
+// Root returns the root node of the tree, it returns false if the tree is
+// empty. The whole tree can be walked from there by Left and Right.
+func (t *Rbtree) Root() (*Node, bool) {
+	if t.root == t.NIL {
+		return nil, false
+	}
+	return t.root, true
 }
 
-// Min return the item minimum one
-func (t *Rbtree) Min() Item {
+// Min returns the minimum item, it returns false if the tree is empty.
+func (t *Rbtree) Min() (Item, bool) {
 	x := t.min(t.root)
 
 	if x == t.NIL {
-		return nil
+		return nil, false
 	}
 
-	return x.Item
+	return x.Item, true
 }
 
-// Max return the item maxmum one
-func (t *Rbtree) Max() Item {
+// Max returns the maximum item, it returns false if the tree is empty.
+func (t *Rbtree) Max() (Item, bool) {
 	x := t.max(t.root)
 
 	if x == t.NIL {
-		return nil
+		return nil, false
 	}
 
-	return x.Item
+	return x.Item, true
+}
+
+// MinMax returns both the minimum and the maximum items, going down the left
+// and the right spines of the tree once each. It returns false if the tree
+// is empty.
+func (t *Rbtree) MinMax() (min, max Item, ok bool) {
+	if t.root == t.NIL {
+		return nil, nil, false
+	}
+	return t.min(t.root).Item, t.max(t.root).Item, true
+}
+
+// Floor returns the largest item which is less or equal than key, it returns
+// false if there is no such item.
+func (t *Rbtree) Floor(key Item) (Item, bool) {
+	if key == nil {
+		return nil, false
+	}
+
+	x := t.floor(key)
+	if x == t.NIL {
+		return nil, false
+	}
+
+	return x.Item, true
+}
+
+// Ceiling returns the smallest item which is greater or equal than key, it
+// returns false if there is no such item.
+func (t *Rbtree) Ceiling(key Item) (Item, bool) {
+	if key == nil {
+		return nil, false
+	}
+
+	x := t.ceiling(key)
+	if x == t.NIL {
+		return nil, false
+	}
+
+	return x.Item, true
+}
+
+// Predecessor returns the largest item which is less than the specified one,
+// which does not have to be in the tree. It returns false if there is no
+// such item.
+func (t *Rbtree) Predecessor(item Item) (Item, bool) {
+	if item == nil {
+		return nil, false
+	}
+
+	x := t.lower(item)
+	if x == t.NIL {
+		return nil, false
+	}
+
+	return x.Item, true
+}
+
+// Successor returns the smallest item which is greater than the specified
+// one, which does not have to be in the tree. It returns false if there is
+// no such item.
+func (t *Rbtree) Successor(item Item) (Item, bool) {
+	if item == nil {
+		return nil, false
+	}
+
+	x := t.higher(item)
+	if x == t.NIL {
+		return nil, false
+	}
+
+	return x.Item, true
+}
+
+// PopMin removes the minimum item from the tree and returns it, it returns
+// false if the tree is empty.
+func (t *Rbtree) PopMin() (Item, bool) {
+	x := t.min(t.root)
+
+	if x == t.NIL {
+		return nil, false
+	}
+
+	item := t.deleteNode(x)
+	t.verify("PopMin", item)
+	return item, true
+}
+
+// PopMax removes the maximum item from the tree and returns it, it returns
+// false if the tree is empty.
+func (t *Rbtree) PopMax() (Item, bool) {
+	x := t.max(t.root)
+
+	if x == t.NIL {
+		return nil, false
+	}
+
+	item := t.deleteNode(x)
+	t.verify("PopMax", item)
+	return item, true
+}
+
+// PopFirstN removes the n smallest items from the tree and returns them in
+// ascending order, or all of them if there are fewer, and an empty slice if
+// n is not positive.
+func (t *Rbtree) PopFirstN(n int) []Item {
+	if n <= 0 {
+		return []Item{}
+	}
+	if n > t.count {
+		n = t.count
+	}
+
+	result := make([]Item, 0, n)
+	for len(result) < n {
+		item := t.deleteNode(t.min(t.root))
+		t.verify("PopFirstN", item)
+		result = append(result, item)
+	}
+	return result
+}
+
+// Rank returns the number of items which are strictly less than the
+// specified one, i.e. its 0-based position in ascending order, and whether
+// the item is present in the tree.
+func (t *Rbtree) Rank(item Item) (int, bool) {
+	if item == nil {
+		return 0, false
+	}
+
+	// Equal items may be on both sides of an equal node if the tree holds
+	// duplicates, so keep going left to count only the lesser ones.
+	rank := 0
+	found := false
+	x := t.root
+	for x != t.NIL {
+		if t.less(x.Item, item) {
+			rank += x.left.size + 1
+			x = x.right
+		} else {
+			if !t.less(item, x.Item) {
+				found = true
+			}
+			x = x.left
+		}
+	}
+
+	return rank, found
+}
+
+// EstimateRank returns the number of items which are less than the
+// specified one. The tree maintains the size of every subtree, so that the
+// rank is summed up during a normal search and is always exact, the same as
+// by Rank.
+func (t *Rbtree) EstimateRank(item Item) int {
+	rank, _ := t.Rank(item)
+	return rank
+}
+
+// PrefixWeight returns the total weight of the items which are strictly
+// less than key, it is summed up in O(log n) during a search of key. It is
+// always 0 if the tree is not made by NewWeighted.
+func (t *Rbtree) PrefixWeight(key Item) float64 {
+	if key == nil || t.weigh == nil {
+		return 0
+	}
+
+	sum := 0.0
+	for x := t.root; x != t.NIL; {
+		if t.less(x.Item, key) {
+			sum += x.left.sum + t.weigh(x.Item)
+			x = x.right
+		} else {
+			x = x.left
+		}
+	}
+	return sum
+}
+
+// TotalWeight returns the total weight of all the items, it is always 0 if
+// the tree is not made by NewWeighted.
+func (t *Rbtree) TotalWeight() float64 { return t.root.sum }
+
+// LowerBound returns the number of items which are less than key, i.e. the
+// index of the first item greater or equal than key in ascending order, the
+// same as the lower_bound of C++ and sort.Search with !less(a[i], key).
+func (t *Rbtree) LowerBound(key Item) int {
+	rank, _ := t.Rank(key)
+	return rank
+}
+
+// UpperBound returns the number of items which are less or equal than key,
+// i.e. the index of the first item greater than key in ascending order, the
+// same as the upper_bound of C++.
+func (t *Rbtree) UpperBound(key Item) int {
+	if key == nil {
+		return 0
+	}
+	return t.rankAfter(key)
+}
+
+// Select returns the k-th smallest item in the tree, counting from 0.
+func (t *Rbtree) Select(k int) (Item, bool) {
+	if k < 0 || k >= t.count {
+		return nil, false
+	}
+
+	return t.selectNode(k).Item, true
+}
+
+// NodeAt returns the item at the 0-based index in ascending order in
+// O(log n), it is the same as Select and returns false if the index is out
+// of range.
+func (t *Rbtree) NodeAt(index int) (Item, bool) {
+	return t.Select(index)
+}
+
+// NthLargest returns the k-th largest item in the tree, counting from 0, so
+// that NthLargest(0) is the maximum one.
+func (t *Rbtree) NthLargest(k int) (Item, bool) {
+	return t.Select(t.count - 1 - k)
+}
+
+// FirstN returns the n smallest items in ascending order, or all of them if
+// there are fewer. Unlike SliceAscendFirstN it returns the items rather than
+// the nodes, and an empty slice if n is not positive.
+func (t *Rbtree) FirstN(n int) []Item {
+	if n <= 0 {
+		return []Item{}
+	}
+	if n > t.count {
+		n = t.count
+	}
+
+	result := make([]Item, 0, n)
+	for x := t.min(t.root); len(result) < n; x = t.successor(x) {
+		result = append(result, x.Item)
+	}
+	return result
+}
+
+// LastN returns the n largest items in descending order, the same as
+// SliceDescendFirstN, or all of them if there are fewer, and an empty slice
+// if n is not positive.
+func (t *Rbtree) LastN(n int) []Item {
+	if n <= 0 {
+		return []Item{}
+	}
+	if n > t.count {
+		n = t.count
+	}
+
+	result := make([]Item, 0, n)
+	for x := t.max(t.root); len(result) < n; x = t.predecessor(x) {
+		result = append(result, x.Item)
+	}
+	return result
+}
+
+// CountRange returns the number of items which are greater or equal than @ge
+// and less than @lt, i.e. in the range [ge, lt), without visiting them.
+func (t *Rbtree) CountRange(ge, lt Item) int {
+	if ge == nil || lt == nil {
+		return 0
+	}
+
+	inf, _ := t.Rank(ge)
+	sup, _ := t.Rank(lt)
+	if sup < inf {
+		return 0
+	}
+	return sup - inf
+}
+
+// RangeInfo returns the number of items which are greater or equal than @lo
+// and less or equal than @hi, i.e. in the range [lo, hi], and whether there
+// are items equal to lo and hi, all in O(log n) without visiting them.
+func (t *Rbtree) RangeInfo(lo, hi Item) (count int, loPresent, hiPresent bool) {
+	if lo == nil || hi == nil {
+		return 0, false, false
+	}
+
+	inf, loPresent := t.Rank(lo)
+	_, hiPresent = t.Rank(hi)
+	if sup := t.rankAfter(hi); sup > inf {
+		count = sup - inf
+	}
+	return count, loPresent, hiPresent
+}
+
+// rankAfter returns the number of items which are less or equal than the
+// specified one.
+func (t *Rbtree) rankAfter(item Item) int {
+	rank := 0
+	x := t.root
+	for x != t.NIL {
+		if t.less(item, x.Item) {
+			x = x.left
+		} else {
+			rank += x.left.size + 1
+			x = x.right
+		}
+	}
+	return rank
 }
@@ -6,7 +6,10 @@ package rbtree
 
 import (
 	"fmt"
+	"math/bits"
+	"math/rand"
 	"reflect"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -44,12 +47,13 @@ func TestMin(t *testing.T) {
 		rbt.Insert(Int(m))
 		m++
 	}
-	if rbt.Len() != uint(n) {
+	if rbt.Len() != n {
 		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), n)
 	}
 
 	for m >= 0 {
-		rbt.Delete(rbt.Min())
+		min, _ := rbt.Min()
+		rbt.Delete(min)
 		m--
 	}
 
@@ -141,7 +145,8 @@ func TestWithGoroutine(t *testing.T) {
 	fmt.Printf("In TestWithGoroutine(), we have deleted [%v] nodes.\n", cnt)
 
 	var ret []Item
-	Cache.rbt.Ascend(Cache.rbt.Min(), func(item Item) bool {
+	min, _ := Cache.rbt.Min()
+	Cache.rbt.Ascend(min, func(item Item) bool {
 		ret = append(ret, item)
 		return true
 	})
@@ -150,3 +155,1409 @@ func TestWithGoroutine(t *testing.T) {
 		t.Errorf("expected %v but got %v", expected, ret)
 	}
 }
+
+// checkSize verifies the subtree size of every node and returns the size
+// of the subtree rooted at x.
+func checkSize(t *testing.T, rbt *Rbtree, x *Node) int {
+	if x == rbt.NIL {
+		return 0
+	}
+	size := checkSize(t, rbt, x.left) + checkSize(t, rbt, x.right) + 1
+	if x.size != size {
+		t.Errorf("node %v has size %d, expect %d", x.Item, x.size, size)
+	}
+	return size
+}
+
+func TestPopFirstN(t *testing.T) {
+	rbt := New()
+	for _, v := range rand.Perm(95) {
+		rbt.Insert(Int(v))
+	}
+	if ret := rbt.PopFirstN(0); ret == nil || len(ret) != 0 || rbt.Len() != 95 {
+		t.Errorf("PopFirstN(0) = %v, expect nothing", ret)
+	}
+
+	var popped []Item
+	for rbt.Len() > 0 {
+		ret := rbt.PopFirstN(10)
+		if len(ret) != 10 && rbt.Len() != 0 {
+			t.Fatalf("PopFirstN(10) = %v, expect 10 items", ret)
+		}
+		if err := rbt.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+		popped = append(popped, ret...)
+	}
+
+	if len(popped) != 95 {
+		t.Fatalf("PopFirstN popped %d items, expect %d", len(popped), 95)
+	}
+	for i, item := range popped {
+		if item != Int(i) {
+			t.Errorf("item %d is %v, expect %v", i, item, Int(i))
+		}
+	}
+	if ret := rbt.PopFirstN(10); len(ret) != 0 {
+		t.Errorf("PopFirstN(10) = %v on an empty tree", ret)
+	}
+}
+
+func TestRankAndSelect(t *testing.T) {
+	rbt := New()
+
+	n := 1000
+	for _, v := range rand.Perm(n) {
+		rbt.Insert(Int(v + 1))
+	}
+	checkSize(t, rbt, rbt.root)
+
+	for k := 0; k < n; k++ {
+		item, ok := rbt.Select(k)
+		if !ok || item != Int(k+1) {
+			t.Fatalf("Select(%d) = %v, %v, expect %v, true", k, item, ok, k+1)
+		}
+		rank, ok := rbt.Rank(item)
+		if !ok || rank != k {
+			t.Fatalf("Rank(%v) = %d, %v, expect %d, true", item, rank, ok, k)
+		}
+	}
+
+	if _, ok := rbt.Select(-1); ok {
+		t.Errorf("Select(-1) is expect not exists")
+	}
+	if _, ok := rbt.Select(n); ok {
+		t.Errorf("Select(%d) is expect not exists", n)
+	}
+	if rank, ok := rbt.Rank(Int(n + 10)); ok || rank != n {
+		t.Errorf("Rank(%d) = %d, %v, expect %d, false", n+10, rank, ok, n)
+	}
+
+	// Keep the odd ones and make sure the sizes survive the rebalancing.
+	for v := 2; v <= n; v += 2 {
+		rbt.Delete(Int(v))
+	}
+	checkSize(t, rbt, rbt.root)
+
+	for k := 0; k < n/2; k++ {
+		item, _ := rbt.Select(k)
+		if item != Int(2*k+1) {
+			t.Fatalf("Select(%d) = %v, expect %v", k, item, 2*k+1)
+		}
+		if rank, ok := rbt.Rank(Int(2*k + 2)); ok || rank != k+1 {
+			t.Fatalf("Rank(%d) = %d, %v, expect %d, false", 2*k+2, rank, ok, k+1)
+		}
+	}
+}
+
+func TestMinMax(t *testing.T) {
+	rbt := New()
+
+	if min, ok := rbt.Min(); ok {
+		t.Errorf("Min() of empty tree = %v, expect nothing", min)
+	}
+	if max, ok := rbt.Max(); ok {
+		t.Errorf("Max() of empty tree = %v, expect nothing", max)
+	}
+	if min, max, ok := rbt.MinMax(); ok {
+		t.Errorf("MinMax() of empty tree = %v, %v, expect nothing", min, max)
+	}
+
+	rbt.Insert(Int(42))
+	if min, ok := rbt.Min(); !ok || min != Int(42) {
+		t.Errorf("Min() = %v, %v, expect %v, true", min, ok, 42)
+	}
+	if max, ok := rbt.Max(); !ok || max != Int(42) {
+		t.Errorf("Max() = %v, %v, expect %v, true", max, ok, 42)
+	}
+	if min, max, ok := rbt.MinMax(); !ok || min != Int(42) || max != Int(42) {
+		t.Errorf("MinMax() = %v, %v, %v, expect %v, %v, true", min, max, ok, 42, 42)
+	}
+
+	for _, v := range rand.Perm(500) {
+		rbt.Insert(Int(v * 3))
+	}
+	nodes := rbt.SliceAscend()
+	if min, ok := rbt.Min(); !ok || min != nodes[0].Item {
+		t.Errorf("Min() = %v, %v, expect %v, true", min, ok, nodes[0].Item)
+	}
+	if max, ok := rbt.Max(); !ok || max != nodes[len(nodes)-1].Item {
+		t.Errorf("Max() = %v, %v, expect %v, true", max, ok, nodes[len(nodes)-1].Item)
+	}
+	if min, max, ok := rbt.MinMax(); !ok || min != nodes[0].Item || max != nodes[len(nodes)-1].Item {
+		t.Errorf("MinMax() = %v, %v, %v, expect %v, %v, true", min, max, ok, nodes[0].Item, nodes[len(nodes)-1].Item)
+	}
+}
+
+func TestPopMin(t *testing.T) {
+	rbt := New()
+
+	n := 1000
+	for _, v := range rand.Perm(n) {
+		rbt.Insert(Int(v))
+	}
+
+	for i := 0; i < n; i++ {
+		item, ok := rbt.PopMin()
+		if !ok || item != Int(i) {
+			t.Fatalf("PopMin() = %v, %v, expect %v, true", item, ok, i)
+		}
+		if rbt.Len() != n-i-1 {
+			t.Fatalf("tree.Len() = %d, expect %d", rbt.Len(), n-i-1)
+		}
+	}
+
+	if item, ok := rbt.PopMin(); ok {
+		t.Errorf("PopMin() of empty tree = %v, expect nothing", item)
+	}
+	if rbt.root != rbt.NIL {
+		t.Errorf("expect the tree to be empty")
+	}
+}
+
+func TestPopMax(t *testing.T) {
+	rbt := New()
+
+	n := 1000
+	for _, v := range rand.Perm(n) {
+		rbt.Insert(Int(v))
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		item, ok := rbt.PopMax()
+		if !ok || item != Int(i) {
+			t.Fatalf("PopMax() = %v, %v, expect %v, true", item, ok, i)
+		}
+	}
+	checkSize(t, rbt, rbt.root)
+
+	if item, ok := rbt.PopMax(); ok {
+		t.Errorf("PopMax() of empty tree = %v, expect nothing", item)
+	}
+}
+
+func TestFloorAndCeiling(t *testing.T) {
+	rbt := New()
+
+	if item, ok := rbt.Floor(Int(1)); ok {
+		t.Errorf("Floor(1) of empty tree = %v, expect nothing", item)
+	}
+	if item, ok := rbt.Ceiling(Int(1)); ok {
+		t.Errorf("Ceiling(1) of empty tree = %v, expect nothing", item)
+	}
+
+	// 10, 20, ..., 100
+	for _, v := range rand.Perm(10) {
+		rbt.Insert(Int((v + 1) * 10))
+	}
+
+	tests := []struct {
+		key              Int
+		floor, ceiling   Item
+		hasFloor, hasCei bool
+	}{
+		{5, nil, Int(10), false, true},
+		{10, Int(10), Int(10), true, true},
+		{11, Int(10), Int(20), true, true},
+		{55, Int(50), Int(60), true, true},
+		{99, Int(90), Int(100), true, true},
+		{100, Int(100), Int(100), true, true},
+		{101, Int(100), nil, true, false},
+	}
+
+	for _, test := range tests {
+		floor, ok := rbt.Floor(test.key)
+		if ok != test.hasFloor || floor != test.floor {
+			t.Errorf("Floor(%v) = %v, %v, expect %v, %v", test.key, floor, ok, test.floor, test.hasFloor)
+		}
+		ceiling, ok := rbt.Ceiling(test.key)
+		if ok != test.hasCei || ceiling != test.ceiling {
+			t.Errorf("Ceiling(%v) = %v, %v, expect %v, %v", test.key, ceiling, ok, test.ceiling, test.hasCei)
+		}
+	}
+}
+
+func TestClear(t *testing.T) {
+	rbt := New()
+
+	for i := 0; i < 100; i++ {
+		rbt.Insert(Int(i))
+	}
+
+	rbt.Clear()
+	if rbt.Len() != 0 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 0)
+	}
+	if min, ok := rbt.Min(); ok {
+		t.Errorf("Min() of cleared tree = %v, expect nothing", min)
+	}
+
+	for i := 50; i < 60; i++ {
+		rbt.Insert(Int(i))
+	}
+	if rbt.Len() != 10 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 10)
+	}
+
+	var ret []Item
+	rbt.Ascend(Int(0), func(i Item) bool {
+		ret = append(ret, i)
+		return true
+	})
+	expected := []Item{Int(50), Int(51), Int(52), Int(53), Int(54), Int(55), Int(56), Int(57), Int(58), Int(59)}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expected %v but got %v", expected, ret)
+	}
+	checkSize(t, rbt, rbt.root)
+}
+
+func TestLenAndIsEmpty(t *testing.T) {
+	rbt := New()
+
+	if rbt.Len() != 0 || !rbt.IsEmpty() {
+		t.Errorf("tree.Len() = %d, IsEmpty() = %v, expect 0, true", rbt.Len(), rbt.IsEmpty())
+	}
+
+	for i := 0; i < 10; i++ {
+		rbt.Insert(Int(i))
+		if rbt.Len() != i+1 {
+			t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), i+1)
+		}
+	}
+
+	// Neither duplicates nor absent items change the count.
+	rbt.Insert(Int(3))
+	rbt.Insert(Int(3))
+	rbt.Delete(Int(100))
+	if rbt.Len() != 10 || rbt.IsEmpty() {
+		t.Errorf("tree.Len() = %d, IsEmpty() = %v, expect 10, false", rbt.Len(), rbt.IsEmpty())
+	}
+
+	for i := 0; i < 10; i++ {
+		rbt.Delete(Int(i))
+		if rbt.Len() != 9-i {
+			t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 9-i)
+		}
+	}
+	if !rbt.IsEmpty() {
+		t.Errorf("expect the tree to be empty")
+	}
+}
+
+func TestContains(t *testing.T) {
+	rbt := New()
+
+	if rbt.Contains(Int(1)) {
+		t.Errorf("1 is expect not exists in empty tree")
+	}
+
+	for i := 0; i < 100; i += 2 {
+		rbt.Insert(Int(i))
+	}
+	rbt.Insert(Int(10))
+
+	for i := 0; i < 100; i++ {
+		if rbt.Contains(Int(i)) != (i%2 == 0) {
+			t.Errorf("Contains(%d) = %v, expect %v", i, !(i%2 == 0), i%2 == 0)
+		}
+	}
+
+	rbt.Delete(Int(10))
+	if rbt.Contains(Int(10)) {
+		t.Errorf("10 is expect not exists")
+	}
+
+	key := Item(Int(42))
+	if allocs := testing.AllocsPerRun(100, func() { rbt.Contains(key) }); allocs != 0 {
+		t.Errorf("Contains() allocates %v times, expect 0", allocs)
+	}
+}
+
+// sameShape reports whether the subtrees rooted at x and y have the same
+// shape, colors and items.
+func sameShape(a *Rbtree, x *Node, b *Rbtree, y *Node) bool {
+	if x == a.NIL || y == b.NIL {
+		return x == a.NIL && y == b.NIL
+	}
+	return x.color == y.color && x.Item == y.Item && x.size == y.size &&
+		sameShape(a, x.left, b, y.left) && sameShape(a, x.right, b, y.right)
+}
+
+func TestClone(t *testing.T) {
+	rbt := New()
+	for _, v := range rand.Perm(100) {
+		rbt.Insert(Int(v))
+	}
+
+	c := rbt.Clone()
+	if !sameShape(rbt, rbt.root, c, c.root) {
+		t.Fatalf("the clone should have the same shape as the original")
+	}
+	if c.Len() != rbt.Len() {
+		t.Errorf("clone.Len() = %d, expect %d", c.Len(), rbt.Len())
+	}
+
+	var expected []Item
+	for _, n := range rbt.SliceAscend() {
+		expected = append(expected, n.Item)
+	}
+
+	for i := 0; i < 100; i += 3 {
+		c.Delete(Int(i))
+	}
+	c.Insert(Int(1000))
+	checkSize(t, c, c.root)
+
+	var ret []Item
+	for _, n := range rbt.SliceAscend() {
+		ret = append(ret, n.Item)
+	}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("the original has been changed to %v", ret)
+	}
+	if c.Len() != 100-34+1 {
+		t.Errorf("clone.Len() = %d, expect %d", c.Len(), 100-34+1)
+	}
+	if rbt.Contains(Int(1000)) || !c.Contains(Int(1000)) {
+		t.Errorf("1000 is expect to exist only in the clone")
+	}
+}
+
+func TestEstimateRank(t *testing.T) {
+	rbt := New()
+	for i := 0; i < 500; i++ {
+		rbt.Insert(Int(rand.Intn(1000)))
+	}
+	for i := 0; i < 200; i++ {
+		rbt.Delete(Int(rand.Intn(1000)))
+	}
+
+	nodes := rbt.SliceAscend()
+	for key := -1; key <= 1000; key += 7 {
+		expected := 0
+		for _, node := range nodes {
+			if node.Item.(Int) < Int(key) {
+				expected++
+			}
+		}
+		if n := rbt.EstimateRank(Int(key)); n != expected {
+			t.Errorf("EstimateRank(%d) = %d, expect %d", key, n, expected)
+		}
+	}
+}
+
+func TestWeights(t *testing.T) {
+	weighers := []Weigher{
+		func(Item) float64 { return 1 },
+		func(i Item) float64 { return float64(i.(Int)%7) + 0.5 },
+	}
+
+	for _, w := range weighers {
+		rbt := NewWeighted(w)
+		if rbt.TotalWeight() != 0 || rbt.PrefixWeight(Int(1)) != 0 {
+			t.Errorf("empty tree has weights %v, %v, expect 0", rbt.TotalWeight(), rbt.PrefixWeight(Int(1)))
+		}
+
+		for i := 0; i < 500; i++ {
+			rbt.Insert(Int(rand.Intn(1000)))
+		}
+		for i := 0; i < 200; i++ {
+			rbt.Delete(Int(rand.Intn(1000)))
+		}
+		if err := rbt.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+
+		nodes := rbt.SliceAscend()
+		total := 0.0
+		for _, node := range nodes {
+			total += w(node.Item)
+		}
+		if rbt.TotalWeight() != total {
+			t.Errorf("TotalWeight() = %v, expect %v", rbt.TotalWeight(), total)
+		}
+
+		for key := -1; key <= 1000; key += 7 {
+			expected := 0.0
+			for _, node := range nodes {
+				if node.Item.(Int) < Int(key) {
+					expected += w(node.Item)
+				}
+			}
+			if sum := rbt.PrefixWeight(Int(key)); sum != expected {
+				t.Errorf("PrefixWeight(%d) = %v, expect %v", key, sum, expected)
+			}
+		}
+
+		// The sums follow the trees made out of rbt as well.
+		left, right := rbt.Split(Int(500))
+		if err := left.Join(right); err != nil {
+			t.Fatal(err)
+		}
+		if err := left.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+		if left.TotalWeight() != total {
+			t.Errorf("TotalWeight() after Split and Join = %v, expect %v", left.TotalWeight(), total)
+		}
+	}
+
+	if sum := newIntTree(1, 2, 3).PrefixWeight(Int(3)); sum != 0 {
+		t.Errorf("PrefixWeight(3) of unweighted tree = %v, expect 0", sum)
+	}
+}
+
+func TestLowerAndUpperBound(t *testing.T) {
+	for _, rbt := range []*Rbtree{New(), NewWithDuplicates(true)} {
+		for i := 0; i < 200; i++ {
+			rbt.Insert(Int(rand.Intn(100)))
+		}
+
+		for key := -1; key <= 100; key++ {
+			lower, upper := 0, 0
+			for _, node := range rbt.SliceAscend() {
+				if node.Item.(Int) < Int(key) {
+					lower++
+				}
+				if node.Item.(Int) <= Int(key) {
+					upper++
+				}
+			}
+			if n := rbt.LowerBound(Int(key)); n != lower {
+				t.Errorf("LowerBound(%d) = %d, expect %d", key, n, lower)
+			}
+			if n := rbt.UpperBound(Int(key)); n != upper {
+				t.Errorf("UpperBound(%d) = %d, expect %d", key, n, upper)
+			}
+		}
+	}
+}
+
+func TestNodeAt(t *testing.T) {
+	rbt := New()
+	for _, v := range rand.Perm(200) {
+		rbt.Insert(Int(v))
+	}
+	for v := 0; v < 200; v += 3 {
+		rbt.Delete(Int(v))
+	}
+
+	nodes := rbt.SliceAscend()
+	for i, node := range nodes {
+		if item, ok := rbt.NodeAt(i); !ok || item != node.Item {
+			t.Errorf("NodeAt(%d) = %v, %v, expect %v, true", i, item, ok, node.Item)
+		}
+	}
+	for _, i := range []int{-1, len(nodes)} {
+		if item, ok := rbt.NodeAt(i); ok {
+			t.Errorf("NodeAt(%d) = %v, expect none", i, item)
+		}
+	}
+}
+
+func TestLastNAndNthLargest(t *testing.T) {
+	rbt := New()
+	if ret := rbt.LastN(3); len(ret) != 0 {
+		t.Errorf("LastN(3) = %v on an empty tree", ret)
+	}
+	if item, ok := rbt.NthLargest(0); ok {
+		t.Errorf("NthLargest(0) = %v on an empty tree", item)
+	}
+
+	for _, v := range rand.Perm(100) {
+		rbt.Insert(Int(v))
+	}
+	desc := rbt.SliceDescend()
+
+	for _, n := range []int{0, 1, 10, 100, 200} {
+		ret := rbt.LastN(n)
+		expected := n
+		if expected > len(desc) {
+			expected = len(desc)
+		}
+		if len(ret) != expected {
+			t.Fatalf("len(LastN(%d)) = %d, expect %d", n, len(ret), expected)
+		}
+		for i, item := range ret {
+			if node := desc[i]; item != node.Item {
+				t.Errorf("LastN(%d)[%d] = %v, expect %v", n, i, item, node.Item)
+			}
+		}
+	}
+
+	for k, node := range desc {
+		if item, ok := rbt.NthLargest(k); !ok || item != node.Item {
+			t.Errorf("NthLargest(%d) = %v, %v, expect %v, true", k, item, ok, node.Item)
+		}
+	}
+	for _, k := range []int{-1, 100} {
+		if item, ok := rbt.NthLargest(k); ok {
+			t.Errorf("NthLargest(%d) = %v, expect none", k, item)
+		}
+	}
+}
+
+func TestFirstNAndLastN(t *testing.T) {
+	tests := []struct {
+		values      []int
+		n           int
+		first, last []Item
+	}{
+		{nil, 3, []Item{}, []Item{}},
+		{[]int{3, 1, 2}, 0, []Item{}, []Item{}},
+		{[]int{3, 1, 2}, -1, []Item{}, []Item{}},
+		{[]int{3, 1, 2}, 1, []Item{Int(1)}, []Item{Int(3)}},
+		{[]int{3, 1, 2, 5, 4}, 2, []Item{Int(1), Int(2)}, []Item{Int(5), Int(4)}},
+		{[]int{3, 1, 2}, 3, []Item{Int(1), Int(2), Int(3)}, []Item{Int(3), Int(2), Int(1)}},
+		{[]int{3, 1, 2}, 10, []Item{Int(1), Int(2), Int(3)}, []Item{Int(3), Int(2), Int(1)}},
+	}
+
+	for _, test := range tests {
+		rbt := newIntTree(test.values...)
+		if ret := rbt.FirstN(test.n); ret == nil || !reflect.DeepEqual(ret, test.first) {
+			t.Errorf("%v: FirstN(%d) = %#v, expect %v", test.values, test.n, ret, test.first)
+		}
+		if ret := rbt.LastN(test.n); ret == nil || !reflect.DeepEqual(ret, test.last) {
+			t.Errorf("%v: LastN(%d) = %#v, expect %v", test.values, test.n, ret, test.last)
+		}
+	}
+}
+
+func TestCountRange(t *testing.T) {
+	dense := New()
+	for _, v := range rand.Perm(100) {
+		dense.Insert(Int(v))
+	}
+	sparse := New()
+	for _, v := range rand.Perm(100) {
+		sparse.Insert(Int(v * 10))
+	}
+
+	tests := []struct {
+		rbt      *Rbtree
+		ge, lt   Int
+		expected int
+	}{
+		{dense, 10, 20, 10},
+		{dense, 0, 100, 100},
+		{dense, -50, 500, 100},
+		{dense, 50, 50, 0},
+		{dense, 60, 40, 0},
+		{dense, 99, 100, 1},
+		{sparse, 10, 20, 1},
+		{sparse, 11, 20, 0},
+		{sparse, 5, 996, 99},
+		{sparse, -10, 0, 0},
+		{sparse, 990, 1000, 1},
+	}
+
+	for _, test := range tests {
+		if n := test.rbt.CountRange(test.ge, test.lt); n != test.expected {
+			t.Errorf("CountRange(%v, %v) = %d, expect %d", test.ge, test.lt, n, test.expected)
+		}
+
+		n := 0
+		test.rbt.AscendRange(test.ge, test.lt, func(i Item) bool {
+			n++
+			return true
+		})
+		if n != test.expected {
+			t.Errorf("AscendRange(%v, %v) visited %d items, expect %d", test.ge, test.lt, n, test.expected)
+		}
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	// Deleting the greatest items mostly shortens the right side of the
+	// tree, so the left side is left deeper than needed. The seed is fixed
+	// since a few random trees happen to be perfectly balanced anyway.
+	rbt := New()
+	for _, v := range rand.New(rand.NewSource(1)).Perm(4096) {
+		rbt.Insert(Int(v))
+	}
+	for i := 256; i < 4096; i++ {
+		rbt.Delete(Int(i))
+	}
+	before, height := items(rbt), rbt.Height()
+
+	rbt.Rebuild()
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if h := rbt.Height(); h >= height || h != bits.Len(uint(rbt.Len())) {
+		t.Errorf("tree.Height() = %d after Rebuild, was %d", h, height)
+	}
+	if !reflect.DeepEqual(items(rbt), before) {
+		t.Errorf("expected %v but got %v", before, items(rbt))
+	}
+}
+
+func TestNewBounded(t *testing.T) {
+	rbt := NewBounded(10)
+	for _, v := range rand.Perm(1000) {
+		rbt.Insert(Int(v + 1))
+		if rbt.Len() > 10 {
+			t.Fatalf("tree.Len() = %d, expect no more than %d", rbt.Len(), 10)
+		}
+	}
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := items(newIntTree(intRange(991, 1001, 1)...)); !reflect.DeepEqual(items(rbt), expected) {
+		t.Errorf("expected %v but got %v", expected, items(rbt))
+	}
+
+	if rbt.Insert(Int(5)) || rbt.Insert(Int(991)) {
+		t.Errorf("tree.Insert() = true for an item not greater than the minimum")
+	}
+	if !rbt.Insert(Int(2000)) || rbt.Contains(Int(991)) || rbt.Len() != 10 {
+		t.Errorf("tree.Insert(2000) does not evict the minimum")
+	}
+
+	if rbt := NewBounded(0); rbt.InsertMany(Int(1), Int(2), Int(3)) != 3 {
+		t.Errorf("NewBounded(0) is bounded")
+	}
+}
+
+func TestInsertMany(t *testing.T) {
+	rbt := New()
+	if n := rbt.InsertMany(Int(3), Int(1), Int(2)); n != 3 {
+		t.Errorf("InsertMany() = %d, expect %d", n, 3)
+	}
+	if n := rbt.InsertMany(Int(2), Int(4), nil, Int(4), Int(5), Int(1)); n != 2 {
+		t.Errorf("InsertMany() = %d, expect %d", n, 2)
+	}
+	if n := rbt.InsertMany(); n != 0 {
+		t.Errorf("InsertMany() = %d, expect %d", n, 0)
+	}
+	if expected := items(newIntTree(1, 2, 3, 4, 5)); !reflect.DeepEqual(items(rbt), expected) {
+		t.Errorf("expected %v but got %v", expected, items(rbt))
+	}
+
+	rbt = NewWithDuplicates(true)
+	if n := rbt.InsertMany(Int(1), Int(1), Int(1)); n != 3 || rbt.Len() != 3 {
+		t.Errorf("InsertMany() = %d, expect %d", n, 3)
+	}
+}
+
+func TestBulkInsertSorted(t *testing.T) {
+	for n := 0; n < 300; n++ {
+		items := make([]Item, n)
+		for i := range items {
+			items[i] = Int(i * 2)
+		}
+
+		rbt := New()
+		if err := rbt.BulkInsertSorted(items); err != nil {
+			t.Fatal(err)
+		}
+		if err := rbt.CheckInvariants(); err != nil {
+			t.Fatalf("%d items: %v", n, err)
+		}
+
+		var ret []Item
+		for _, node := range rbt.SliceAscend() {
+			ret = append(ret, node.Item)
+		}
+		if len(ret) != n || (n > 0 && !reflect.DeepEqual(ret, items)) {
+			t.Fatalf("expected %v but got %v", items, ret)
+		}
+	}
+}
+
+func TestBulkInsertSortedMerge(t *testing.T) {
+	rbt := New()
+	for i := 0; i < 100; i += 3 {
+		rbt.Insert(&testStruct{i, "old"})
+	}
+
+	var items []Item
+	for i := 0; i < 100; i += 2 {
+		items = append(items, &testStruct{i, "new"})
+	}
+	if err := rbt.BulkInsertSorted(items); err != nil {
+		t.Fatal(err)
+	}
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+
+	if rbt.Len() != 67 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 67)
+	}
+	for _, node := range rbt.SliceAscend() {
+		ts := node.Item.(*testStruct)
+		if expected := map[bool]string{true: "new", false: "old"}[ts.id%2 == 0]; ts.text != expected {
+			t.Errorf("item %d is %q, expect %q", ts.id, ts.text, expected)
+		}
+	}
+}
+
+func TestBulkInsertSortedUnique(t *testing.T) {
+	var sorted []Item
+	for i := 0; i < 100; i++ {
+		// Runs of 1 to 5 equal items, the last one of each is kept.
+		for j := 0; j <= i%5; j++ {
+			sorted = append(sorted, &testStruct{i, string(rune('a' + j))})
+		}
+	}
+
+	rbt := New()
+	if err := rbt.BulkInsertSortedUnique(sorted); err != nil {
+		t.Fatal(err)
+	}
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if rbt.Len() != 100 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 100)
+	}
+	for i, node := range rbt.SliceAscend() {
+		ts := node.Item.(*testStruct)
+		if expected := string(rune('a' + i%5)); ts.id != i || ts.text != expected {
+			t.Errorf("item %d is %d %q, expect %d %q", i, ts.id, ts.text, i, expected)
+		}
+	}
+
+	// The runs are merged with the tree the same way as BulkInsertSorted.
+	rbt = newIntTree(0, 5, 10)
+	if err := rbt.BulkInsertSortedUnique([]Item{Int(1), Int(1), Int(5), Int(5), Int(5), Int(7)}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []Item{Int(0), Int(1), Int(5), Int(7), Int(10)}; !reflect.DeepEqual(items(rbt), expected) {
+		t.Errorf("expected %v but got %v", expected, items(rbt))
+	}
+
+	for _, bad := range [][]Item{{Int(1), Int(3), Int(2)}, {Int(1), nil}} {
+		if err := rbt.BulkInsertSortedUnique(bad); err != ErrNotSorted {
+			t.Errorf("BulkInsertSortedUnique(%v) = %v, expect %v", bad, err, ErrNotSorted)
+		}
+	}
+	if rbt.Len() != 5 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 5)
+	}
+}
+
+func TestBulkInsertSortedNotSorted(t *testing.T) {
+	rbt := New()
+	rbt.Insert(Int(10))
+
+	tests := [][]Item{
+		{Int(1), Int(3), Int(2)},
+		{Int(1), Int(1)},
+		{Int(1), nil},
+	}
+	for _, items := range tests {
+		if err := rbt.BulkInsertSorted(items); err != ErrNotSorted {
+			t.Errorf("BulkInsertSorted(%v) = %v, expect %v", items, err, ErrNotSorted)
+		}
+	}
+	if rbt.Len() != 1 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 1)
+	}
+}
+
+func TestPredecessorAndSuccessor(t *testing.T) {
+	rbt := New()
+
+	if item, ok := rbt.Predecessor(Int(1)); ok {
+		t.Errorf("Predecessor(1) of empty tree = %v, expect nothing", item)
+	}
+	if item, ok := rbt.Successor(Int(1)); ok {
+		t.Errorf("Successor(1) of empty tree = %v, expect nothing", item)
+	}
+
+	// 10, 20, ..., 100
+	for _, v := range rand.Perm(10) {
+		rbt.Insert(Int((v + 1) * 10))
+	}
+
+	tests := []struct {
+		item         Int
+		pred, succ   Item
+		hasPred, has bool
+	}{
+		{5, nil, Int(10), false, true},
+		{10, nil, Int(20), false, true},
+		{11, Int(10), Int(20), true, true},
+		{50, Int(40), Int(60), true, true},
+		{55, Int(50), Int(60), true, true},
+		{100, Int(90), nil, true, false},
+		{101, Int(100), nil, true, false},
+	}
+
+	for _, test := range tests {
+		pred, ok := rbt.Predecessor(test.item)
+		if ok != test.hasPred || pred != test.pred {
+			t.Errorf("Predecessor(%v) = %v, %v, expect %v, %v", test.item, pred, ok, test.pred, test.hasPred)
+		}
+		succ, ok := rbt.Successor(test.item)
+		if ok != test.has || succ != test.succ {
+			t.Errorf("Successor(%v) = %v, %v, expect %v, %v", test.item, succ, ok, test.succ, test.has)
+		}
+	}
+}
+
+func TestInit(t *testing.T) {
+	rbt := NewReverse()
+	for i := 0; i < 10; i++ {
+		rbt.Insert(Int(i))
+	}
+	if ret := rbt.Init(); ret != rbt || rbt.Len() != 0 {
+		t.Errorf("Init() = %p with %d items, expect %p with %d", ret, ret.Len(), rbt, 0)
+	}
+
+	// The comparator is kept.
+	rbt.InsertMany(Int(1), Int(3), Int(2))
+	if expected := []Item{Int(3), Int(2), Int(1)}; !reflect.DeepEqual(items(rbt), expected) {
+		t.Errorf("expected %v but got %v", expected, items(rbt))
+	}
+
+	// So is the pool, which takes the nodes back.
+	pooled := NewWithPool()
+	n := pooled.InsertNode(Int(1))
+	pooled.Init()
+	if !n.IsNil() || n.Item != nil {
+		t.Errorf("node of %v is not put back to the pool by Init", n.Item)
+	}
+	if pooled.pool == nil {
+		t.Errorf("Init() drops the pool")
+	}
+}
+
+func TestNewWithPool(t *testing.T) {
+	rbt := NewWithPool()
+
+	for round := 0; round < 3; round++ {
+		for _, v := range rand.Perm(1000) {
+			rbt.Insert(Int(v))
+		}
+		for v := 0; v < 1000; v += 2 {
+			rbt.Delete(Int(v))
+		}
+		for v := 0; v < 1000; v += 2 {
+			rbt.Insert(Int(v))
+		}
+		if err := rbt.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Every node must be linked exactly once.
+		seen := make(map[*Node]bool)
+		for _, n := range rbt.SliceAscend() {
+			if seen[n] {
+				t.Fatalf("node of %v is linked twice", n.Item)
+			}
+			seen[n] = true
+		}
+		if len(seen) != 1000 {
+			t.Fatalf("there are %d nodes, expect %d", len(seen), 1000)
+		}
+
+		for i := 0; i < 500; i++ {
+			rbt.PopMin()
+		}
+		rbt.Clear()
+	}
+}
+
+func benchmarkChurn(b *testing.B, rbt *Rbtree) {
+	for i := 0; i < 1000; i++ {
+		rbt.Insert(Int(i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rbt.Delete(Int(i % 1000))
+		rbt.Insert(Int(i % 1000))
+	}
+}
+
+func BenchmarkChurn(b *testing.B) {
+	benchmarkChurn(b, New())
+}
+
+func BenchmarkChurnWithPool(b *testing.B) {
+	benchmarkChurn(b, NewWithPool())
+}
+
+func TestInsertNodeAndDeleteNode(t *testing.T) {
+	rbt := New()
+	handles := map[Int]*Node{}
+	for _, v := range rand.Perm(100) {
+		handles[Int(v)] = rbt.InsertNode(Int(v))
+	}
+	if n := rbt.InsertNode(Int(42)); n != handles[42] {
+		t.Errorf("InsertNode(42) = %p, expect the existing node %p", n, handles[42])
+	}
+	if n := rbt.InsertNode(nil); n != nil {
+		t.Errorf("InsertNode(nil) = %p, expect nil", n)
+	}
+
+	// The handles survive the unrelated insertions and deletions.
+	for v := 100; v < 200; v++ {
+		rbt.Insert(Int(v))
+	}
+	for v := 0; v < 200; v += 3 {
+		if v < 100 {
+			if !rbt.DeleteNode(handles[Int(v)]) {
+				t.Errorf("DeleteNode(%d) = false, expect true", v)
+			}
+			delete(handles, Int(v))
+		} else {
+			rbt.Delete(Int(v))
+		}
+		if err := rbt.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for v, n := range handles {
+		if n.Item != v {
+			t.Errorf("the node of %v carries %v", v, n.Item)
+		}
+	}
+
+	for _, n := range handles {
+		rbt.DeleteNode(n)
+	}
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range rbt.SliceAscend() {
+		if node.Item.(Int) < 100 {
+			t.Errorf("%v is expect not exists", node.Item)
+		}
+	}
+
+	if rbt.DeleteNode(nil) || rbt.DeleteNode(rbt.NIL) {
+		t.Errorf("DeleteNode() = true for nil or NIL")
+	}
+}
+
+func TestInsertNodeDuplicates(t *testing.T) {
+	rbt := NewWithDuplicates(true)
+	first := rbt.InsertNode(&testStruct{1, "a"})
+	if n := rbt.InsertNode(&testStruct{1, "b"}); n != first {
+		t.Errorf("InsertNode(1) = %p, expect the existing node %p", n, first)
+	}
+	if rbt.Len() != 1 || first.Item.(*testStruct).text != "a" {
+		t.Errorf("tree.Len() = %d with %v, expect %d with a", rbt.Len(), first.Item, 1)
+	}
+	if x := rbt.InsertOrGet(&testStruct{1, "c"}); x != first.Item {
+		t.Errorf("InsertOrGet(1) = %v, expect %v the same as InsertNode", x, first.Item)
+	}
+
+	// The equal items inserted by Insert are found after the first one.
+	rbt.Insert(&testStruct{1, "d"})
+	if n := rbt.InsertNode(&testStruct{1, "e"}); n != first || rbt.Len() != 2 {
+		t.Errorf("InsertNode(1) = %v with %d items, expect %v with %d", n.Item, rbt.Len(), first.Item, 2)
+	}
+}
+
+func TestDeleteNodeDetached(t *testing.T) {
+	rbt := New()
+	for i := 0; i < 100; i++ {
+		rbt.Insert(Int(i))
+	}
+
+	nodes := rbt.SliceAscend()
+	rand.Shuffle(len(nodes), func(i, j int) { nodes[i], nodes[j] = nodes[j], nodes[i] })
+	for i, n := range nodes {
+		item := n.Item
+		if !rbt.DeleteNode(n) {
+			t.Fatalf("DeleteNode(%v) = false, expect true", item)
+		}
+		if rbt.DeleteNode(n) {
+			t.Fatalf("DeleteNode(%v) = true twice", item)
+		}
+		if rbt.Contains(item) || rbt.Len() != len(nodes)-i-1 {
+			t.Fatalf("%v is expect not exists", item)
+		}
+		if err := rbt.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	other := newIntTree(1, 2, 3)
+	rbt.Insert(Int(2))
+	if n := other.Search(Int(2)); rbt.DeleteNode(n) || other.Len() != 3 || rbt.Len() != 1 {
+		t.Errorf("DeleteNode() = true for a node of another tree")
+	}
+
+	n := rbt.Search(Int(2))
+	rbt.Clear()
+	rbt.Insert(Int(2))
+	if rbt.DeleteNode(n) {
+		t.Errorf("DeleteNode() = true for a node dropped by Clear")
+	}
+}
+
+func TestDeleteNodePool(t *testing.T) {
+	rbt := NewWithPool()
+	for i := 0; i < 10; i++ {
+		h := rbt.InsertNode(Int(100 + i))
+		if !rbt.DeleteNode(h) {
+			t.Fatalf("DeleteNode(%v) = false, expect true", Int(100+i))
+		}
+		rbt.Insert(Int(200 + i))
+		if rbt.DeleteNode(h) {
+			t.Fatalf("DeleteNode(%v) = true twice", Int(100+i))
+		}
+	}
+	if rbt.Len() != 10 || !rbt.Contains(Int(200)) {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 10)
+	}
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteAll(t *testing.T) {
+	rbt := NewWithDuplicates(true)
+	for i := 0; i < 10; i++ {
+		rbt.Insert(Int(i))
+	}
+	for i := 0; i < 5; i++ {
+		rbt.Insert(Int(5))
+	}
+	if rbt.Len() != 15 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 15)
+	}
+
+	if n := rbt.DeleteAll(Int(5)); n != 6 {
+		t.Errorf("DeleteAll(5) = %d, expect %d", n, 6)
+	}
+	if n := rbt.DeleteAll(Int(5)); n != 0 {
+		t.Errorf("DeleteAll(5) = %d, expect %d", n, 0)
+	}
+	if n := rbt.DeleteAll(Int(100)); n != 0 {
+		t.Errorf("DeleteAll(100) = %d, expect %d", n, 0)
+	}
+	if rbt.Len() != 9 || rbt.Contains(Int(5)) {
+		t.Errorf("tree.Len() = %d, expect %d without 5", rbt.Len(), 9)
+	}
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Error(err)
+	}
+
+	rbt = New()
+	rbt.Insert(Int(5))
+	rbt.Insert(Int(5))
+	if n := rbt.DeleteAll(Int(5)); n != 1 {
+		t.Errorf("DeleteAll(5) = %d, expect %d", n, 1)
+	}
+}
+
+func TestBulkDelete(t *testing.T) {
+	for _, batch := range []int{1, 10, 1000} {
+		rbt := New()
+		for i := 1; i <= 1000; i++ {
+			rbt.Insert(Int(i))
+		}
+
+		var items []Item
+		for i := 2; i <= 1000; i += 2 {
+			items = append(items, Int(i))
+		}
+		// The absent ones and the repeated ones are skipped.
+		items = append(items, Int(0), Int(2000), Int(2), nil)
+		rand.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+
+		n := 0
+		for i := 0; i < len(items); i += batch {
+			n += rbt.BulkDelete(items[i:min(i+batch, len(items))])
+		}
+		if n != 500 {
+			t.Errorf("BulkDelete() = %d, expect %d", n, 500)
+		}
+		if err := rbt.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+
+		ret := rbt.SliceAscend()
+		if len(ret) != 500 {
+			t.Fatalf("len(SliceAscend()) = %d, expect %d", len(ret), 500)
+		}
+		for i, node := range ret {
+			if node.Item != Int(2*i+1) {
+				t.Errorf("item %d is %v, expect %v", i, node.Item, Int(2*i+1))
+			}
+		}
+	}
+}
+
+func TestRangeDelete(t *testing.T) {
+	tests := []struct {
+		lo, hi, removed int
+	}{
+		{400, 600, 200},
+		{500, 510, 10},
+		{0, 1, 1},
+		{999, 2000, 1},
+		{600, 400, 0},
+		{2000, 3000, 0},
+		{-100, 2000, 1000},
+	}
+
+	for _, test := range tests {
+		rbt := New()
+		for _, v := range rand.Perm(1000) {
+			rbt.Insert(Int(v))
+		}
+
+		if n := rbt.RangeDelete(Int(test.lo), Int(test.hi)); n != test.removed {
+			t.Errorf("RangeDelete(%d, %d) = %d, expect %d", test.lo, test.hi, n, test.removed)
+		}
+		if err := rbt.CheckInvariants(); err != nil {
+			t.Fatal(err)
+		}
+
+		var expected []Item
+		for v := 0; v < 1000; v++ {
+			if v < test.lo || v >= test.hi {
+				expected = append(expected, Int(v))
+			}
+		}
+		if !reflect.DeepEqual(items(rbt), expected) {
+			t.Errorf("RangeDelete(%d, %d) left %v", test.lo, test.hi, items(rbt))
+		}
+	}
+}
+
+func TestNoDuplicates(t *testing.T) {
+	for _, rbt := range []*Rbtree{New(), NewWithDuplicates(false)} {
+		first := &testStruct{1, "first"}
+		second := &testStruct{1, "second"}
+		rbt.Insert(first)
+		rbt.Insert(&testStruct{2, "other"})
+		rbt.Insert(second)
+
+		if rbt.Len() != 2 {
+			t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 2)
+		}
+		if item, _ := rbt.Get(&testStruct{1, ""}); item.(*testStruct) != second {
+			t.Errorf("tree.Get = %v, expect %v", item, second)
+		}
+		if n := rbt.CountRange(&testStruct{1, ""}, &testStruct{2, ""}); n != 1 {
+			t.Errorf("tree.CountRange(1, 2) = %d, expect %d", n, 1)
+		}
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	rbt := NewWithDuplicates(true)
+	for i := 0; i < 100; i++ {
+		rbt.Insert(&testStruct{i % 10, strconv.Itoa(i)})
+	}
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Fatal(err)
+	}
+	if rbt.Len() != 100 {
+		t.Errorf("tree.Len() = %d, expect %d", rbt.Len(), 100)
+	}
+
+	// The equal items are kept in the order of their insertion.
+	ret := rbt.SliceAscend()
+	for i, node := range ret {
+		ts := node.Item.(*testStruct)
+		if expected := strconv.Itoa(i%10*10 + i/10); ts.id != i/10 || ts.text != expected {
+			t.Errorf("item %d is %v, expect {%d %s}", i, ts, i/10, expected)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		if item, _ := rbt.Get(&testStruct{i, ""}); item.(*testStruct).text != strconv.Itoa(i) {
+			t.Errorf("tree.Get(%d) = %v, expect the first one", i, item)
+		}
+		if rank, ok := rbt.Rank(&testStruct{i, ""}); rank != i*10 || !ok {
+			t.Errorf("tree.Rank(%d) = %d, %v, expect %d, true", i, rank, ok, i*10)
+		}
+	}
+
+	if n := rbt.CountRange(&testStruct{3, ""}, &testStruct{5, ""}); n != 20 {
+		t.Errorf("tree.CountRange(3, 5) = %d, expect %d", n, 20)
+	}
+	if n := rbt.CountRange(&testStruct{3, ""}, &testStruct{3, ""}); n != 0 {
+		t.Errorf("tree.CountRange(3, 3) = %d, expect %d", n, 0)
+	}
+
+	// Delete removes the first of the equal items.
+	if item := rbt.Delete(&testStruct{3, ""}); item.(*testStruct).text != "3" {
+		t.Errorf("tree.Delete(3) = %v, expect the first one", item)
+	}
+	if item, _ := rbt.Get(&testStruct{3, ""}); item.(*testStruct).text != "13" {
+		t.Errorf("tree.Get(3) = %v, expect the second one", item)
+	}
+	if n := rbt.CountRange(&testStruct{3, ""}, &testStruct{5, ""}); n != 19 {
+		t.Errorf("tree.CountRange(3, 5) = %d, expect %d", n, 19)
+	}
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Error(err)
+	}
+
+	if err := rbt.BulkInsertSorted([]Item{&testStruct{4, "a"}, &testStruct{4, "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := rbt.CountRange(&testStruct{4, ""}, &testStruct{5, ""}); n != 12 {
+		t.Errorf("tree.CountRange(4, 5) = %d, expect %d", n, 12)
+	}
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetEqual(t *testing.T) {
+	// The items are ordered by id and told apart by their text.
+	equal := func(a, b Item) bool {
+		return a.(*testStruct).text == b.(*testStruct).text
+	}
+
+	rbt := NewWithDuplicates(true)
+	rbt.SetEqual(equal)
+	a, b := &testStruct{1, "a"}, &testStruct{1, "b"}
+	rbt.Insert(a)
+	rbt.Insert(b)
+	rbt.Insert(&testStruct{2, "b"})
+
+	if item, ok := rbt.Get(&testStruct{1, "b"}); !ok || item.(*testStruct) != b {
+		t.Errorf("tree.Get({1 b}) = %v, %v, expect %v, true", item, ok, b)
+	}
+	if item, ok := rbt.Get(&testStruct{1, "a"}); !ok || item.(*testStruct) != a {
+		t.Errorf("tree.Get({1 a}) = %v, %v, expect %v, true", item, ok, a)
+	}
+	if rbt.Contains(&testStruct{1, "c"}) {
+		t.Errorf("{1 c} is expect not exists")
+	}
+
+	updated := &testStruct{1, "b"}
+	if old, replaced := rbt.Replace(updated); !replaced || old.(*testStruct) != b {
+		t.Errorf("Replace() = %v, %v, expect %v, true", old, replaced, b)
+	}
+	if item, _ := rbt.Get(&testStruct{1, "b"}); item.(*testStruct) != updated {
+		t.Errorf("tree.Get({1 b}) = %v, expect %v", item, updated)
+	}
+	if old, replaced := rbt.Replace(&testStruct{1, "c"}); replaced {
+		t.Errorf("Replace() = %v, %v, expect nil, false", old, replaced)
+	}
+	if rbt.Len() != 4 || !rbt.Contains(&testStruct{1, "c"}) {
+		t.Errorf("tree.Len() = %d, expect %d with {1 c}", rbt.Len(), 4)
+	}
+	if err := rbt.CheckInvariants(); err != nil {
+		t.Error(err)
+	}
+
+	// Without duplicates, there is room for a single item at each position.
+	rbt = New()
+	rbt.SetEqual(equal)
+	rbt.Insert(a)
+	if rbt.Contains(&testStruct{1, "b"}) {
+		t.Errorf("{1 b} is expect not exists")
+	}
+	if old, replaced := rbt.Replace(b); !replaced || old.(*testStruct) != a {
+		t.Errorf("Replace() = %v, %v, expect %v, true", old, replaced, a)
+	}
+	if rbt.Len() != 1 || !rbt.Contains(&testStruct{1, "b"}) {
+		t.Errorf("tree.Len() = %d, expect %d with {1 b}", rbt.Len(), 1)
+	}
+
+	rbt.SetEqual(nil)
+	if !rbt.Contains(&testStruct{1, "c"}) {
+		t.Errorf("{1 c} is expect exists")
+	}
+}
+
+func TestRangeInfo(t *testing.T) {
+	// The even numbers in [0, 100).
+	rbt := newIntTree(intRange(0, 100, 2)...)
+
+	tests := []struct {
+		lo, hi               Int
+		count                int
+		loPresent, hiPresent bool
+	}{
+		{10, 20, 6, true, true},
+		{11, 19, 4, false, false},
+		{10, 19, 5, true, false},
+		{11, 20, 5, false, true},
+		{20, 20, 1, true, true},
+		{21, 21, 0, false, false},
+		{20, 10, 0, true, true},
+		{-10, 200, 50, false, false},
+		{98, 200, 1, true, false},
+	}
+
+	for _, test := range tests {
+		count, loPresent, hiPresent := rbt.RangeInfo(test.lo, test.hi)
+		if count != test.count || loPresent != test.loPresent || hiPresent != test.hiPresent {
+			t.Errorf("RangeInfo(%d, %d) = %d, %v, %v, expect %d, %v, %v", test.lo, test.hi,
+				count, loPresent, hiPresent, test.count, test.loPresent, test.hiPresent)
+		}
+	}
+
+	rbt = NewWithDuplicates(true)
+	rbt.InsertMany(Int(1), Int(2), Int(2), Int(2), Int(3))
+	if count, loPresent, hiPresent := rbt.RangeInfo(Int(2), Int(2)); count != 3 || !loPresent || !hiPresent {
+		t.Errorf("RangeInfo(2, 2) = %d, %v, %v, expect 3, true, true", count, loPresent, hiPresent)
+	}
+}
+
+// ptrInt is an item which may be a nil pointer, which goes before all the
+// other ones.
+type ptrInt struct{ v int }
+
+func (p *ptrInt) Less(than Item) bool {
+	q := than.(*ptrInt)
+	if p == nil || q == nil {
+		return p == nil && q != nil
+	}
+	return p.v < q.v
+}
+
+func TestNilPointerItem(t *testing.T) {
+	var null *ptrInt
+	rbt := New()
+	if !rbt.Insert(null) {
+		t.Fatalf("Insert(nil pointer) = false, expect true")
+	}
+	if rbt.Len() != 1 {
+		t.Fatalf("tree.Len() = %d, expect %d", rbt.Len(), 1)
+	}
+
+	check := func(name string, item Item, ok bool) {
+		if !ok || item != Item(null) {
+			t.Errorf("%s = %v, %v, expect the nil pointer and true", name, item, ok)
+		}
+	}
+	item, ok := rbt.Get(null)
+	check("Get()", item, ok)
+	item, ok = rbt.Min()
+	check("Min()", item, ok)
+	item, ok = rbt.Max()
+	check("Max()", item, ok)
+	item, ok = rbt.Floor(null)
+	check("Floor()", item, ok)
+	item, ok = rbt.Ceiling(null)
+	check("Ceiling()", item, ok)
+	if !rbt.Contains(null) {
+		t.Errorf("Contains(nil pointer) = false, expect true")
+	}
+
+	rbt.Insert(&ptrInt{1})
+	item, ok = rbt.Floor(&ptrInt{0})
+	check("Floor(0)", item, ok)
+	if item, ok = rbt.Ceiling(&ptrInt{2}); ok {
+		t.Errorf("Ceiling(2) = %v, expect nothing", item)
+	}
+
+	// The deleted nil pointer is told apart from nothing deleted.
+	if item := rbt.Delete(null); item == nil || item != Item(null) {
+		t.Errorf("Delete(nil pointer) = %v, expect the nil pointer", item)
+	}
+	if item := rbt.Delete(null); item != nil {
+		t.Errorf("Delete(nil pointer) = %#v after deleting it, expect nil", item)
+	}
+	if item, ok := rbt.Get(null); ok {
+		t.Errorf("Get(nil pointer) = %v after deleting it, expect nothing", item)
+	}
+}